@@ -0,0 +1,118 @@
+package nestedtext
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// Byte slices are encoded as text, by default using standard base64. The "hex" and
+// "base64url" tag options select hexadecimal or URL-safe base64 instead. Long
+// encodings are wrapped into multi-line strings, see WithBinaryWidth; decoding
+// ignores white space, so that the line breaks do not matter.
+
+const (
+	binaryBase64    = "base64"
+	binaryBase64URL = "base64url"
+	binaryHex       = "hex"
+)
+
+// binaryEncoding returns the encoding of byte slices for a field. It is safe to
+// call on a nil fieldInfo.
+func (fi *fieldInfo) binaryEncoding() string {
+	if fi == nil || fi.binary == "" {
+		return binaryBase64
+	}
+	return fi.binary
+}
+
+// isByteSlice reports whether t is a slice of bytes.
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// decodeBinary decodes a NestedText string into a byte slice, using the given
+// encoding. For compatibility, lists of numbers are decoded element-wise.
+func (d *Decoder) decodeBinary(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind == parse.ListNode {
+		return d.decodeSlice(n, v, fi)
+	}
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	s := strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, n.Value)
+
+	var b []byte
+	var err error
+	switch encoding := fi.binaryEncoding(); encoding {
+	case binaryHex:
+		b, err = hex.DecodeString(s)
+	case binaryBase64URL:
+		b, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	default:
+		b, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: "string " + quoteShort(n.Value),
+			Type:  v.Type(),
+			Err:   err,
+		}
+	}
+	v.SetBytes(b)
+	return nil
+}
+
+// quoteShort quotes s, abbreviating it if it is long, as binary data may be.
+func quoteShort(s string) string {
+	const max = 32
+	if len(s) > max {
+		return fmt.Sprintf("%q...", s[:max])
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// formatBinary encodes b as text using the given encoding, broken into lines of at
+// most width characters if width is positive.
+func formatBinary(b []byte, encoding string, width int) string {
+	var s string
+	switch encoding {
+	case binaryHex:
+		s = hex.EncodeToString(b)
+	case binaryBase64URL:
+		s = base64.URLEncoding.EncodeToString(b)
+	default:
+		s = base64.StdEncoding.EncodeToString(b)
+	}
+	if width <= 0 || len(s) <= width {
+		return s
+	}
+	var sb strings.Builder
+	for len(s) > width {
+		sb.WriteString(s[:width])
+		sb.WriteByte('\n')
+		s = s[width:]
+	}
+	sb.WriteString(s)
+	return sb.String()
+}
+
+// formatBinaries encodes the byte slices in v as text using the given encoding.
+func (enc *Encoder) formatBinaries(v reflect.Value, encoding string) interface{} {
+	return formatElements(v, isByteSlice, func(v reflect.Value) interface{} {
+		return formatBinary(v.Bytes(), encoding, enc.binaryWidth)
+	})
+}
@@ -0,0 +1,85 @@
+package nestedtext
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	type Secrets struct {
+		Cert  []byte
+		Hash  []byte   `nt:"hash,hex"`
+		Token []byte   `nt:"token,base64url"`
+		Keys  [][]byte `nt:"keys,hex"`
+	}
+	cert := bytes.Repeat([]byte("certificate data "), 5)
+	in := Secrets{
+		Cert:  cert,
+		Hash:  []byte{0xde, 0xad, 0xbe, 0xef},
+		Token: []byte{0xfb, 0xff, 0xfe},
+		Keys:  [][]byte{{0x01}, {0x02, 0x03}},
+	}
+
+	b, err := Marshal(in, WithBinaryWidth(40))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `Cert:
+  > Y2VydGlmaWNhdGUgZGF0YSBjZXJ0aWZpY2F0ZSBk
+  > YXRhIGNlcnRpZmljYXRlIGRhdGEgY2VydGlmaWNh
+  > dGUgZGF0YSBjZXJ0aWZpY2F0ZSBkYXRhIA==
+hash: deadbeef
+keys:
+  - 01
+  - 0203
+token: -__-
+`
+	if string(b) != want {
+		t.Errorf("Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	var out Secrets
+	if err := Unmarshal(b, &out); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(out, in) {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+
+	b, err = Marshal(map[string][]byte{"cert": cert}, WithBinaryWidth(0))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Count(string(b), "\n") != 1 {
+		t.Errorf("WithBinaryWidth(0): got %q, want a single line", b)
+	}
+}
+
+func TestUnmarshalBinary(t *testing.T) {
+	var v struct {
+		Data []byte
+		Hash []byte `nt:"hash,hex"`
+	}
+	input := "data: aGVsbG8\nhash:\n  > DEAD\n  > beef\n"
+	if err := Unmarshal([]byte(input), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if string(v.Data) != "hello" || !bytes.Equal(v.Hash, []byte{0xde, 0xad, 0xbe, 0xef}) {
+		t.Errorf("got Data %q, Hash %x", v.Data, v.Hash)
+	}
+
+	// lists of numbers are still accepted
+	if err := Unmarshal([]byte("data:\n  - 104\n  - 105\n"), &v); err != nil || string(v.Data) != "hi" {
+		t.Errorf("list of numbers: Data = %q, err = %v", v.Data, err)
+	}
+
+	for _, input := range []string{"data: not base64!", "hash: xyz"} {
+		var typeErr *UnmarshalTypeError
+		if err := Unmarshal([]byte(input), &v); !errors.As(err, &typeErr) || typeErr.Err == nil {
+			t.Errorf("Unmarshal(%q) = %v, want *UnmarshalTypeError with cause", input, err)
+		}
+	}
+}
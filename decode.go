@@ -2,12 +2,18 @@ package nestedtext
 
 import (
 	"bytes"
+	"encoding"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
-	"sync"
+	"time"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
 )
 
 // Unmarshal parses NestedText data and stores the result in the value pointed to by v.
@@ -15,15 +21,58 @@ import (
 //
 // Unmarshal uses the following rules to decode values:
 //
-//   - Structs are decoded from NestedText dicts. Keys are matched to struct field names
-//     (case-insensitive) or the `nt` tag if present.
-//   - Slices are decoded from NestedText lists.
-//   - Maps are decoded from NestedText dicts.
+//   - Structs are decoded from NestedText dicts. Keys are matched to the `nt` tag name
+//     or its aliases if present, otherwise to struct field names (case-insensitive,
+//     unless ExactKeys is given) as transformed by FieldNaming, if given.
+//     Distinct keys matching the same field result in a *KeyConflictError. Keys
+//     without a matching field are skipped, unless the DisallowUnknownFields option
+//     is given. Fields tagged
+//     `nt:",required"` must be present; all missing ones are reported in a single
+//     *RequiredFieldError.
+//     Absent fields tagged `nt:",default=value"` are set to value, unless they
+//     already hold a non-zero value.
+//     Fields of embedded structs, and of struct fields tagged `nt:",inline"`, are
+//     promoted into the enclosing dict.
+//   - Slices are decoded from NestedText lists. Byte slices are decoded from base64
+//     strings, or hexadecimal or URL-safe base64 for fields tagged `nt:",hex"` or
+//     `nt:",base64url"`, ignoring white space.
+//   - Pre-populated values are overlaid according to a MergePolicy: slices are
+//     replaced, while maps and structs are merged, keeping entries and fields absent
+//     from the input. See SliceMerge, MapMerge, StructMerge and the "merge=" tag option.
+//   - Arrays are decoded from NestedText lists with exactly as many items as the
+//     array has elements; see ZeroFillArrays. Otherwise an *ArrayLengthError is returned.
+//   - Maps are decoded from NestedText dicts. Map keys may be strings, integers,
+//     floating point numbers or booleans, which are coerced like leaf values.
 //   - Strings are decoded directly.
 //   - Numeric types (int, float64, etc.) are decoded from NestedText strings using strconv.
-//   - Booleans are decoded from strings: "true"/"false" or "1"/"0".
+//     Integers are decimal unless NumericLiterals is in effect. Integer fields tagged
+//     `nt:",bytes"` additionally accept size units, such as "512Ki" or "10MB".
+//   - Booleans are decoded from strings: "true"/"false" or "1"/"0". Other spellings
+//     can be configured with BoolStrings or HumanBools.
+//   - Complex numbers are decoded with strconv.ParseComplex. big.Int, big.Float and
+//     big.Rat values are decoded from their decimal representation exactly, without
+//     going through float64; big.Rat also accepts fractions, such as "1/3".
+//   - time.Duration values are decoded with time.ParseDuration. time.Time values are
+//     decoded as RFC 3339, or using the layout given by a `nt:",layout=..."` tag.
+//   - Values of types registered with DecodeFunc are decoded with the registered
+//     function, which takes precedence over all other rules.
+//   - Values implementing NodeUnmarshaler are decoded with UnmarshalNTNode, others
+//     implementing Unmarshaler with UnmarshalNT. Otherwise, values
+//     implementing encoding.TextUnmarshaler are decoded from strings with UnmarshalText.
+//     Map keys implementing encoding.TextUnmarshaler are decoded the same way.
+//   - Interface values receive strings, []interface{} and map[string]interface{}
+//     values, or typed values as inferred by the InferTypes rules. With OrderedDicts,
+//     dicts are decoded into *OrderedMap values.
+//   - OrderedMap values are decoded from NestedText dicts in document order.
 //
 // Type coercion automatically converts NestedText strings to the target Go type.
+// Decoding errors report the line and column of the offending item, together with
+// its path in terms of document keys, such as "servers[2].host".
+//
+// Decoded values are validated bottom-up: struct fields against the constraints of
+// their "ntvalidate" tag, and values implementing Validator with ValidateNT.
+// Violations are reported as *ValidationError values; they do not stop decoding,
+// so that all of them are reported together in an ErrorList.
 func Unmarshal(data []byte, v interface{}, opts ...DecodeOption) error {
 	d := NewDecoder(bytes.NewReader(data), opts...)
 	return d.Decode(v)
@@ -31,9 +80,32 @@ func Unmarshal(data []byte, v interface{}, opts ...DecodeOption) error {
 
 // Decoder reads and decodes NestedText values from an input stream.
 type Decoder struct {
-	r           io.Reader
-	opts        []DecodeOption
-	minimalMode bool
+	r                     io.Reader
+	opts                  []DecodeOption
+	minimalMode           bool
+	disallowUnknownFields bool
+	zeroFillArrays        bool
+	collectErrors         bool
+	bools                 *boolVocabulary // nil for the default vocabulary
+	numericLiterals       bool
+	exactKeys             bool
+	mergePolicies         map[reflect.Kind]MergePolicy
+	stream                *parse.Streamer // set once Token or More has been called
+	converters            map[reflect.Type]func(string) (reflect.Value, error)
+	naming                NamingStrategy
+	structInfos           structInfos // struct metadata, if naming is not comparable
+	inferRules            []InferRule
+	orderedDicts          bool
+	expandSeparator       string               // set by ExpandKeys
+	sourceName            string               // set by WithSourceName
+	keepStrings           [][]string           // key paths exempted from inference, split into segments
+	keptNodes             map[*parse.Node]bool // nodes at the keepStrings paths of the current value
+}
+
+// structInfo returns the metadata of struct type t under the decoder's naming
+// strategy. The strategy is the same for every call, as the options are.
+func (d *Decoder) structInfo(t reflect.Type) *structInfo {
+	return getStructInfo(t, d.naming, &d.structInfos)
 }
 
 // NewDecoder returns a new decoder that reads from r.
@@ -42,7 +114,13 @@ func NewDecoder(r io.Reader, opts ...DecodeOption) *Decoder {
 }
 
 // Decode reads the next NestedText value from its input and stores it in the value pointed to by v.
-func (d *Decoder) Decode(v interface{}) error {
+//
+// Once Token or More has been called, Decode reads the next value from the token
+// stream instead, such as the next item of a list, or the value following a key
+// read with Token. It returns io.EOF at the end of the input.
+func (d *Decoder) Decode(v interface{}) (err error) {
+	defer func() { err = withSourceName(err, d.sourceName) }()
+
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Pointer || rv.IsNil() {
 		return makeNestedTextError(ErrCodeUnmarshal, "Decode requires non-nil pointer argument")
@@ -55,312 +133,798 @@ func (d *Decoder) Decode(v interface{}) error {
 		}
 	}
 
-	parsed, err := parseWithConfig(d.r, d.minimalMode)
+	var root *parse.Node
+	if d.stream != nil {
+		if root, err = d.readNode(); err == nil && root == nil {
+			err = io.EOF
+		}
+	} else {
+		root, err = parseNodeWithConfig(d.r, d.minimalMode)
+	}
+	if err == nil && d.expandSeparator != "" {
+		root, err = expandKeys(root, d.expandSeparator)
+	}
 	if err != nil {
 		return err
 	}
 
-	return decode(parsed, rv.Elem())
-}
-
-// structInfo holds cached metadata about a struct type.
-type structInfo struct {
-	fields []fieldInfo
-}
-
-// fieldInfo holds metadata about a single struct field.
-type fieldInfo struct {
-	name      string       // Go field name
-	index     int          // field index
-	tag       string       // nt tag name (empty if not specified)
-	omitEmpty bool         // omitempty option
-	ignore    bool         // "-" tag
-	fieldType reflect.Type // field type
-}
-
-// structInfoCache caches struct metadata to avoid repeated reflection.
-var structInfoCache sync.Map // map[reflect.Type]*structInfo
+	d.markKeptStrings(root)
 
-// getStructInfo returns cached struct metadata for the given type.
-func getStructInfo(t reflect.Type) *structInfo {
-	if cached, ok := structInfoCache.Load(t); ok {
-		return cached.(*structInfo)
+	// An empty document still yields the declared defaults, but lacks required fields
+	if root == nil && rv.Elem().Kind() == reflect.Struct {
+		err = d.decodeEmpty(rv.Elem())
+	} else {
+		err = d.decode(root, rv.Elem(), nil)
 	}
-
-	info := &structInfo{
-		fields: make([]fieldInfo, 0, t.NumField()),
-	}
-
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if !field.IsExported() {
-			continue
-		}
-
-		fi := fieldInfo{
-			name:      field.Name,
-			index:     i,
-			fieldType: field.Type,
-		}
-
-		tagOpts := parseNTTag(field.Tag.Get("nt"))
-		fi.ignore = tagOpts.ignore
-		fi.omitEmpty = tagOpts.omitEmpty
-		if tagOpts.name != "" {
-			fi.tag = tagOpts.name
-		}
-
-		info.fields = append(info.fields, fi)
+	if _, ok := err.(ErrorList); d.collectErrors && err != nil && !ok {
+		err = ErrorList{err}
 	}
-
-	structInfoCache.Store(t, info)
-	return info
+	return err
 }
 
-// decode recursively populates v from parsed NestedText data.
-func decode(data interface{}, v reflect.Value) error {
-	// Handle nil data
-	if data == nil {
+// decode recursively populates v from a parsed NestedText node.
+// If v is (part of) a struct field, fi holds the field's metadata; it is passed on
+// to the elements of lists and dicts, so that tag options concerning the format of
+// values apply to them as well.
+func (d *Decoder) decode(n *parse.Node, v reflect.Value, fi *fieldInfo) (err error) {
+	// Handle empty documents
+	if n == nil {
 		return nil
 	}
+	decoded := v
+	defer func() {
+		// Validate bottom-up, once the nested values are complete
+		if err == nil || isValidationError(err) {
+			err = joinErrors(err, validateValue(n, decoded))
+		}
+		setErrorPosition(err, n)
+	}()
 
-	// Allocate pointer if needed
-	for v.Kind() == reflect.Pointer {
+	// Allocate pointer if needed, unless a decoder function is registered for it
+	for {
+		if conv, ok := d.converters[v.Type()]; ok {
+			return decodeConverted(n, v, conv)
+		}
+		if v.Kind() != reflect.Pointer {
+			break
+		}
 		if v.IsNil() {
 			v.Set(reflect.New(v.Type().Elem()))
 		}
 		v = v.Elem()
 	}
 
-	// Check for Unmarshaler interface on addressable values
+	// Check for Unmarshaler and encoding.TextUnmarshaler interfaces on addressable values
 	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(NodeUnmarshaler); ok {
+			return unmarshalNode(u, n)
+		}
 		if u, ok := v.Addr().Interface().(Unmarshaler); ok {
-			return u.UnmarshalNT(data)
+			return u.UnmarshalNT(n.Interface())
+		}
+	}
+
+	// Check for types with built-in coercion rules
+	switch v.Type() {
+	case durationType:
+		return decodeDuration(n, v)
+	case timeType:
+		return decodeTime(n, v, fi.timeLayout())
+	case bigIntType, bigFloatType, bigRatType:
+		return d.decodeBigNumber(n, v)
+	case orderedMapType:
+		return d.decodeOrderedMap(n, v)
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			if n.Kind != parse.StringNode {
+				return &UnmarshalTypeError{
+					Value: typeNameOf(n),
+					Type:  v.Type(),
+				}
+			}
+			if err := u.UnmarshalText([]byte(n.Value)); err != nil {
+				return &UnmarshalTypeError{
+					Value: fmt.Sprintf("string %q", n.Value),
+					Type:  v.Type(),
+					Err:   err,
+				}
+			}
+			return nil
 		}
 	}
 
 	switch v.Kind() {
 	case reflect.Interface:
 		// For interface{}, just set the value directly
-		v.Set(reflect.ValueOf(data))
+		d.setInterface(n, v)
 		return nil
 
 	case reflect.String:
-		return decodeString(data, v)
+		return decodeString(n, v)
 
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		return decodeInt(data, v)
+		return d.decodeInt(n, v, fi)
 
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		return decodeUint(data, v)
+		return d.decodeUint(n, v, fi)
 
 	case reflect.Float32, reflect.Float64:
-		return decodeFloat(data, v)
+		return decodeFloat(n, v)
+
+	case reflect.Complex64, reflect.Complex128:
+		return decodeComplex(n, v)
 
 	case reflect.Bool:
-		return decodeBool(data, v)
+		return d.decodeBool(n, v)
 
 	case reflect.Slice:
-		return decodeSlice(data, v)
+		if isByteSlice(v.Type()) {
+			return d.decodeBinary(n, v, fi)
+		}
+		return d.decodeSlice(n, v, fi)
+
+	case reflect.Array:
+		return d.decodeArray(n, v, fi)
 
 	case reflect.Map:
-		return decodeMap(data, v)
+		return d.decodeMap(n, v, fi)
 
 	case reflect.Struct:
-		return decodeStruct(data, v)
+		return d.decodeStruct(n, v, fi)
 
 	default:
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
 }
 
 // decodeString decodes a NestedText string into a Go string.
-func decodeString(data interface{}, v reflect.Value) error {
-	s, ok := data.(string)
-	if !ok {
+func decodeString(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
+	s := n.Value
 	v.SetString(s)
 	return nil
 }
 
+// unmarshalNode calls the UnmarshalNTNode method of u, making sure that errors it
+// returns carry a position.
+func unmarshalNode(u NodeUnmarshaler, n *parse.Node) error {
+	err := u.UnmarshalNTNode(newNode(n))
+	var nte NestedTextError
+	if err == nil || errors.As(err, &nte) {
+		return err
+	}
+	wrapped := wrapError(ErrCodeUnmarshal, err.Error(), err)
+	wrapped.Line, wrapped.Column = n.LineNo, n.ColNo
+	return wrapped
+}
+
+// decodeConverted decodes a NestedText string with a function registered with
+// DecodeFunc.
+func decodeConverted(n *parse.Node, v reflect.Value, conv func(string) (reflect.Value, error)) error {
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	converted, err := conv(n.Value)
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", n.Value),
+			Type:  v.Type(),
+			Err:   err,
+		}
+	}
+	v.Set(converted)
+	return nil
+}
+
 // decodeInt decodes a NestedText string into a Go int type.
-func decodeInt(data interface{}, v reflect.Value) error {
-	s, ok := data.(string)
-	if !ok {
+// Fields tagged "bytes" may carry a size unit.
+func (d *Decoder) decodeInt(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.StringNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
+	s := n.Value
 
-	n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+	bits := v.Type().Bits()
+	digits, base := d.intLiteral(s)
+	num, err := strconv.ParseInt(digits, base, bits)
+	if err != nil && fi != nil && fi.bytes {
+		var size uint64
+		if size, err = d.parseByteSize(s); err == nil && size > 1<<(bits-1)-1 {
+			err = strconv.ErrRange
+		}
+		num = int64(size)
+	}
 	if err != nil {
 		return &UnmarshalTypeError{
 			Value: fmt.Sprintf("string %q", s),
 			Type:  v.Type(),
 		}
 	}
-	v.SetInt(n)
+	v.SetInt(num)
 	return nil
 }
 
 // decodeUint decodes a NestedText string into a Go uint type.
-func decodeUint(data interface{}, v reflect.Value) error {
-	s, ok := data.(string)
-	if !ok {
+// Fields tagged "bytes" may carry a size unit.
+func (d *Decoder) decodeUint(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.StringNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
+	s := n.Value
 
-	n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+	bits := v.Type().Bits()
+	digits, base := d.intLiteral(s)
+	num, err := strconv.ParseUint(digits, base, bits)
+	if err != nil && fi != nil && fi.bytes {
+		if num, err = d.parseByteSize(s); err == nil && bits < 64 && num >= 1<<bits {
+			err = strconv.ErrRange
+		}
+	}
 	if err != nil {
 		return &UnmarshalTypeError{
 			Value: fmt.Sprintf("string %q", s),
 			Type:  v.Type(),
 		}
 	}
-	v.SetUint(n)
+	v.SetUint(num)
 	return nil
 }
 
+// intLiteral prepares s for parsing as an integer, returning the string to parse
+// and the base to parse it in. Without NumericLiterals, integers are decimal. With
+// it, Go integer literals with a base prefix ("0x", "0o" or "0b") and underscores
+// are accepted, while numbers without a prefix remain decimal, leading zeros
+// notwithstanding.
+func (d *Decoder) intLiteral(s string) (string, int) {
+	if !d.numericLiterals {
+		return s, 10
+	}
+	sign, digits := "", s
+	if strings.HasPrefix(digits, "-") || strings.HasPrefix(digits, "+") {
+		sign, digits = digits[:1], digits[1:]
+	}
+	if len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("xXoObB", rune(digits[1])) {
+		return s, 0
+	}
+	// Base 0 checks the placement of underscores, but reads a leading zero as
+	// the legacy octal prefix, so leading zeros are dropped
+	if trimmed := strings.TrimLeft(digits, "0"); trimmed != "" || digits == "" {
+		digits = trimmed
+	} else {
+		digits = "0"
+	}
+	return sign + digits, 0
+}
+
+// byteUnits lists the size units accepted by fields tagged "bytes". Longer
+// suffixes come first, so that they are matched before their own suffixes.
+var byteUnits = []struct {
+	suffix string
+	factor uint64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40}, {"PiB", 1 << 50}, {"EiB", 1 << 60},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40}, {"Pi", 1 << 50}, {"Ei", 1 << 60},
+	{"kB", 1e3}, {"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12}, {"PB", 1e15}, {"EB", 1e18},
+	{"k", 1e3}, {"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12}, {"P", 1e15}, {"E", 1e18},
+	{"B", 1},
+}
+
+// parseByteSize parses a size with a unit, such as "512Ki" or "10 MB", into a
+// number of bytes. The number is parsed like other integers, see intLiteral.
+func (d *Decoder) parseByteSize(s string) (uint64, error) {
+	for _, u := range byteUnits {
+		if !strings.HasSuffix(s, u.suffix) {
+			continue
+		}
+		digits, base := d.intLiteral(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)))
+		num, err := strconv.ParseUint(digits, base, 64)
+		if err != nil {
+			return 0, err
+		}
+		if num > math.MaxUint64/u.factor {
+			return 0, strconv.ErrRange
+		}
+		return num * u.factor, nil
+	}
+	return 0, strconv.ErrSyntax
+}
+
 // decodeFloat decodes a NestedText string into a Go float type.
-func decodeFloat(data interface{}, v reflect.Value) error {
-	s, ok := data.(string)
-	if !ok {
+func decodeFloat(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
+	s := n.Value
 
-	n, err := strconv.ParseFloat(s, v.Type().Bits())
+	num, err := strconv.ParseFloat(s, v.Type().Bits())
 	if err != nil {
 		return &UnmarshalTypeError{
 			Value: fmt.Sprintf("string %q", s),
 			Type:  v.Type(),
 		}
 	}
-	v.SetFloat(n)
+	v.SetFloat(num)
 	return nil
 }
 
 // decodeBool decodes a NestedText string into a Go bool.
-// Accepts: "true"/"false", "1"/"0" (case-sensitive).
-func decodeBool(data interface{}, v reflect.Value) error {
-	s, ok := data.(string)
-	if !ok {
+// Accepts the spellings of the decoder's boolean vocabulary, by default
+// "true"/"false", "1"/"0" (case-sensitive).
+func (d *Decoder) decodeBool(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
+	s := n.Value
 
-	switch s {
-	case "true", "1":
-		v.SetBool(true)
-	case "false", "0":
-		v.SetBool(false)
-	default:
+	vocab := d.bools
+	if vocab == nil {
+		vocab = &defaultBoolVocabulary
+	}
+	b, ok := vocab.lookup(s)
+	if !ok {
 		return &UnmarshalTypeError{
 			Value: fmt.Sprintf("string %q", s),
 			Type:  v.Type(),
 		}
 	}
+	v.SetBool(b)
+	return nil
+}
+
+// boolVocabulary holds the spellings accepted for boolean values.
+type boolVocabulary struct {
+	trueStrings  []string
+	falseStrings []string
+	foldCase     bool // match case-insensitively
+}
+
+var defaultBoolVocabulary = boolVocabulary{
+	trueStrings:  []string{"true", "1"},
+	falseStrings: []string{"false", "0"},
+}
+
+// lookup returns the boolean value spelled s, and whether s is a known spelling.
+func (vocab *boolVocabulary) lookup(s string) (value, ok bool) {
+	match := func(spellings []string) bool {
+		for _, sp := range spellings {
+			if s == sp || (vocab.foldCase && strings.EqualFold(s, sp)) {
+				return true
+			}
+		}
+		return false
+	}
+	if match(vocab.trueStrings) {
+		return true, true
+	}
+	if match(vocab.falseStrings) {
+		return false, true
+	}
+	return false, false
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+)
+
+// decodeDuration decodes a NestedText string into a time.Duration, using
+// time.ParseDuration.
+func decodeDuration(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	dur, err := time.ParseDuration(n.Value)
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", n.Value),
+			Type:  v.Type(),
+			Err:   err,
+		}
+	}
+	v.SetInt(int64(dur))
+	return nil
+}
+
+// decodeTime decodes a NestedText string into a time.Time, using the given layout.
+func decodeTime(n *parse.Node, v reflect.Value, layout string) error {
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	t, err := time.Parse(layout, n.Value)
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", n.Value),
+			Type:  v.Type(),
+			Err:   err,
+		}
+	}
+	v.Set(reflect.ValueOf(t))
 	return nil
 }
 
 // decodeSlice decodes a NestedText list into a Go slice.
-func decodeSlice(data interface{}, v reflect.Value) error {
-	list, ok := data.([]interface{})
-	if !ok {
+func (d *Decoder) decodeSlice(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.ListNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
 
-	slice := reflect.MakeSlice(v.Type(), len(list), len(list))
-	for i, item := range list {
-		if err := decode(item, slice.Index(i)); err != nil {
-			if ute, ok := err.(*UnmarshalTypeError); ok {
-				ute.Path = fmt.Sprintf("[%d]%s", i, ute.Path)
+	policy, err := d.mergePolicy(reflect.Slice, fi)
+	if err != nil {
+		return err
+	}
+	// Items are decoded onto the elements from offset on
+	var slice reflect.Value
+	offset := 0
+	switch policy {
+	case MergeReplace:
+		slice = reflect.MakeSlice(v.Type(), len(n.Items), len(n.Items))
+	case MergeDeep:
+		length := len(n.Items)
+		if v.Len() > length {
+			length = v.Len()
+		}
+		slice = reflect.MakeSlice(v.Type(), length, length)
+		reflect.Copy(slice, v)
+	case MergeAppend:
+		offset = v.Len()
+		slice = reflect.MakeSlice(v.Type(), offset+len(n.Items), offset+len(n.Items))
+		reflect.Copy(slice, v)
+	}
+
+	var errs ErrorList
+	for i, item := range n.Items {
+		if err := d.decode(item, slice.Index(offset+i), fi); err != nil {
+			prefixErrorPath(err, fmt.Sprintf("[%d]", i), fmt.Sprintf("[%d]", i))
+			if !d.collectErrors && !isValidationError(err) {
+				return err
 			}
-			return err
+			errs = errs.add(err)
 		}
 	}
 	v.Set(slice)
-	return nil
+	return errs.err()
+}
+
+// decodeArray decodes a NestedText list into a Go array. The list must have as
+// many items as the array has elements, unless ZeroFillArrays is in effect, in
+// which case shorter lists are allowed and the remaining elements are zeroed.
+func (d *Decoder) decodeArray(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.ListNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	if len(n.Items) > v.Len() || (len(n.Items) < v.Len() && !d.zeroFillArrays) {
+		return &ArrayLengthError{
+			Len:    len(n.Items),
+			Type:   v.Type(),
+			Line:   n.LineNo,
+			Column: n.ColNo,
+		}
+	}
+
+	var errs ErrorList
+	for i, item := range n.Items {
+		if err := d.decode(item, v.Index(i), fi); err != nil {
+			prefixErrorPath(err, fmt.Sprintf("[%d]", i), fmt.Sprintf("[%d]", i))
+			if !d.collectErrors && !isValidationError(err) {
+				return err
+			}
+			errs = errs.add(err)
+		}
+	}
+	zero := reflect.Zero(v.Type().Elem())
+	for i := len(n.Items); i < v.Len(); i++ {
+		v.Index(i).Set(zero)
+	}
+	return errs.err()
 }
 
 // decodeMap decodes a NestedText dict into a Go map.
-func decodeMap(data interface{}, v reflect.Value) error {
-	dict, ok := data.(map[string]interface{})
-	if !ok {
+func (d *Decoder) decodeMap(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.DictNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
 
-	// Only support string keys
-	if v.Type().Key().Kind() != reflect.String {
+	// Keys implementing encoding.TextUnmarshaler are decoded with UnmarshalText,
+	// unless a decoder function is registered for them; other keys are coerced
+	// like leaf values.
+	keyType := v.Type().Key()
+	_, convKeys := d.converters[keyType]
+	textKeys := !convKeys && reflect.PointerTo(keyType).Implements(textUnmarshalerType)
+	if !textKeys && !convKeys && !isScalarKind(keyType.Kind()) {
 		return &UnmarshalTypeError{
 			Value: "dict",
 			Type:  v.Type(),
 		}
 	}
 
-	if v.IsNil() {
+	policy, err := d.mergePolicy(reflect.Map, fi)
+	if err != nil {
+		return err
+	}
+	if v.IsNil() || policy == MergeReplace {
 		v.Set(reflect.MakeMap(v.Type()))
 	}
 
 	elemType := v.Type().Elem()
-	for key, val := range dict {
-		elemValue := reflect.New(elemType).Elem()
-		if err := decode(val, elemValue); err != nil {
-			if ute, ok := err.(*UnmarshalTypeError); ok {
-				ute.Path = "." + key + ute.Path
+	var errs ErrorList
+	for i, val := range n.Items {
+		key := n.Keys[i].Value
+		keyValue := reflect.New(keyType)
+		var err error
+		if textKeys {
+			if keyErr := keyValue.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); keyErr != nil {
+				err = &UnmarshalTypeError{
+					Value:  fmt.Sprintf("string %q", key),
+					Type:   keyType,
+					Line:   n.Keys[i].LineNo,
+					Column: n.Keys[i].ColNo,
+					Err:    keyErr,
+				}
 			}
-			return err
+		} else {
+			err = d.decode(n.Keys[i], keyValue.Elem(), nil)
+		}
+		if err != nil {
+			prefixErrorPath(err, "."+key, key)
+		}
+		if err == nil {
+			keyValue = keyValue.Elem()
+			elemValue := reflect.New(elemType).Elem()
+			// Existing entries are replaced, unless MergeDeep is selected
+			if existing := v.MapIndex(keyValue); existing.IsValid() && policy == MergeDeep {
+				elemValue.Set(existing)
+			}
+			if err = d.decode(val, elemValue, fi); err != nil {
+				prefixErrorPath(err, "."+key, key)
+			}
+			if err == nil || isValidationError(err) {
+				v.SetMapIndex(keyValue, elemValue)
+			}
+		}
+		if err != nil {
+			if !d.collectErrors && !isValidationError(err) {
+				return err
+			}
+			errs = errs.add(err)
 		}
-		v.SetMapIndex(reflect.ValueOf(key), elemValue)
 	}
-	return nil
+	return errs.err()
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isScalarKind reports whether values of kind k are coerced from NestedText strings.
+func isScalarKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
 }
 
 // decodeStruct decodes a NestedText dict into a Go struct.
-func decodeStruct(data interface{}, v reflect.Value) error {
-	dict, ok := data.(map[string]interface{})
-	if !ok {
+// If the struct is (part of) a field, fi holds the field's metadata.
+func (d *Decoder) decodeStruct(n *parse.Node, v reflect.Value, fi *fieldInfo) error {
+	if n.Kind != parse.DictNode {
 		return &UnmarshalTypeError{
-			Value: typeNameOf(data),
+			Value: typeNameOf(n),
 			Type:  v.Type(),
 		}
 	}
 
-	info := getStructInfo(v.Type())
+	policy, err := d.mergePolicy(reflect.Struct, fi)
+	if err != nil {
+		return err
+	}
+	if policy == MergeReplace {
+		v.Set(reflect.Zero(v.Type()))
+	}
+
+	info := d.structInfo(v.Type())
+	if err := checkConstraintTags(info); err != nil {
+		return err
+	}
+	present := make(map[*fieldInfo]*parse.Node, len(n.Items)) // field -> key
 
-	for key, val := range dict {
-		fi := findField(info, key)
+	var errs ErrorList
+	for i, val := range n.Items {
+		key := n.Keys[i]
+		fi := findField(info, key.Value, d.exactKeys)
 		if fi == nil {
+			if d.disallowUnknownFields {
+				err := &UnknownFieldError{
+					Key:         key.Value,
+					Type:        v.Type(),
+					Path:        "." + v.Type().Name(),
+					KeyPath:     key.Value,
+					Suggestions: suggestFields(info, key.Value),
+					Line:        key.LineNo,
+					Column:      key.ColNo,
+				}
+				if !d.collectErrors {
+					return err
+				}
+				errs = errs.add(err)
+			}
 			// Unknown field, skip it
 			continue
 		}
 
-		field := v.Field(fi.index)
-		if err := decode(val, field); err != nil {
-			if ute, ok := err.(*UnmarshalTypeError); ok {
-				ute.Path = "." + v.Type().Name() + "." + fi.name + ute.Path
+		if prev := present[fi]; prev != nil {
+			err := &KeyConflictError{
+				Key:      key.Value,
+				Previous: prev.Value,
+				Field:    fi.name,
+				Type:     v.Type(),
+				Path:     "." + v.Type().Name(),
+				KeyPath:  key.Value,
+				Line:     key.LineNo,
+				Column:   key.ColNo,
+			}
+			if !d.collectErrors {
+				return err
+			}
+			errs = errs.add(err)
+			continue
+		}
+		present[fi] = key
+		field := fieldByIndex(v, fi.index)
+		err := d.decode(val, field, fi)
+		if (err == nil || isValidationError(err)) && fi.constraints != nil {
+			err = joinErrors(err, validateField(fi, val, field))
+		}
+		if err != nil {
+			prefixErrorPath(err, "."+v.Type().Name()+"."+fi.name, key.Value)
+			if !d.collectErrors && !isValidationError(err) {
+				return err
 			}
+			errs = errs.add(err)
+		}
+	}
+
+	if missing := missingFields(info, present); len(missing) > 0 {
+		err := &RequiredFieldError{
+			Fields: missing,
+			Type:   v.Type(),
+			Path:   "." + v.Type().Name(),
+			Line:   n.LineNo,
+			Column: n.ColNo,
+		}
+		if !d.collectErrors {
+			return err
+		}
+		errs = errs.add(err)
+	}
+
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if present[fi] != nil {
+			continue
+		}
+		if err := d.applyDefault(fi, v); err != nil {
+			return err
+		}
+	}
+	return errs.err()
+}
+
+// decodeEmpty decodes an empty document into struct v: it applies the declared
+// defaults and reports the required fields as missing.
+func (d *Decoder) decodeEmpty(v reflect.Value) error {
+	info := d.structInfo(v.Type())
+	if err := checkConstraintTags(info); err != nil {
+		return err
+	}
+	if err := d.applyDefaults(v); err != nil {
+		return err
+	}
+	if missing := missingFields(info, nil); len(missing) > 0 {
+		return &RequiredFieldError{
+			Fields: missing,
+			Type:   v.Type(),
+			Path:   "." + v.Type().Name(),
+		}
+	}
+	return nil
+}
+
+// missingFields returns the keys of the required fields which are not present, in
+// struct order.
+func missingFields(info *structInfo, present map[*fieldInfo]*parse.Node) []string {
+	var missing []string
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if fi.required && present[fi] == nil {
+			missing = append(missing, fi.key())
+		}
+	}
+	return missing
+}
+
+// parseDefaultValue parses the value of a "default=" tag option. Inline lists and
+// dicts are parsed as NestedText; anything else is taken as a plain string.
+func parseDefaultValue(s string) (*parse.Node, error) {
+	if strings.HasPrefix(s, "[") || strings.HasPrefix(s, "{") {
+		return parseNodeWithConfig(strings.NewReader(s), false)
+	}
+	return parse.NewStringNode(s, 0, 0), nil
+}
+
+// applyDefault sets field fi of struct v, which is absent from the input, to the
+// default value declared in its tag. Fields already holding a non-zero value are
+// left untouched. Absent struct fields without a default of their own receive the
+// defaults of their fields.
+func (d *Decoder) applyDefault(fi *fieldInfo, v reflect.Value) error {
+	if !fi.hasDefault {
+		if fi.fieldType.Kind() == reflect.Struct && d.structInfo(fi.fieldType).hasDefaults {
+			return d.applyDefaults(fieldByIndex(v, fi.index))
+		}
+		return nil
+	}
+	// Nil pointers to embedded structs are only allocated to hold a default
+	if field, ok := lookupField(v, fi.index); ok && !field.IsZero() {
+		return nil
+	}
+	field := fieldByIndex(v, fi.index)
+	err := fi.defaultErr
+	if err == nil {
+		err = d.decode(fi.defaultNode, field, fi)
+	}
+	if err != nil {
+		return wrapError(ErrCodeUsage,
+			fmt.Sprintf("invalid default value %q for field %s: %v", fi.defaultValue, fi.name, err), err)
+	}
+	return nil
+}
+
+// applyDefaults applies the declared defaults to all fields of struct v.
+func (d *Decoder) applyDefaults(v reflect.Value) error {
+	info := d.structInfo(v.Type())
+	for i := range info.fields {
+		if err := d.applyDefault(&info.fields[i], v); err != nil {
 			return err
 		}
 	}
@@ -368,28 +932,36 @@ func decodeStruct(data interface{}, v reflect.Value) error {
 }
 
 // findField finds a struct field matching the given key.
-// Matches by tag name first, then by field name (case-insensitive).
-func findField(info *structInfo, key string) *fieldInfo {
+// Matches by tag name first, then by alias, then by field name or the key derived
+// from it by the naming strategy (case-insensitive, unless exact).
+func findField(info *structInfo, key string, exact bool) *fieldInfo {
 	keyLower := strings.ToLower(key)
 
 	// First pass: match by tag
 	for i := range info.fields {
 		fi := &info.fields[i]
-		if fi.ignore {
-			continue
-		}
 		if fi.tag == key {
 			return fi
 		}
 	}
 
-	// Second pass: match by field name (case-insensitive)
+	// Second pass: match by alias
 	for i := range info.fields {
 		fi := &info.fields[i]
-		if fi.ignore {
+		for _, alias := range fi.aliases {
+			if alias == key {
+				return fi
+			}
+		}
+	}
+
+	// Third pass: match by field name (case-insensitive, unless exact)
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if fi.tag != "" {
 			continue
 		}
-		if fi.tag == "" && strings.ToLower(fi.name) == keyLower {
+		if name := fi.key(); name == key || (!exact && strings.ToLower(name) == keyLower) {
 			return fi
 		}
 	}
@@ -397,30 +969,342 @@ func findField(info *structInfo, key string) *fieldInfo {
 	return nil
 }
 
+// maxSuggestions limits the number of field names offered by an UnknownFieldError.
+const maxSuggestions = 3
+
+// suggestFields returns the keys of the fields in info which are closest to key,
+// ordered by edit distance. Keys which are too far off to be plausible typos are
+// not considered.
+func suggestFields(info *structInfo, key string) []string {
+	type candidate struct {
+		name string
+		dist int
+	}
+	keyLower := strings.ToLower(key)
+	limit := 1 + len(keyLower)/3
+	var candidates []candidate
+	for i := range info.fields {
+		fi := &info.fields[i]
+		name := fi.key()
+		if dist := editDistance(keyLower, strings.ToLower(name)); dist <= limit {
+			candidates = append(candidates, candidate{name: name, dist: dist})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+	if len(candidates) > maxSuggestions {
+		candidates = candidates[:maxSuggestions]
+	}
+	var names []string
+	for _, c := range candidates {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// editDistance computes the optimal string alignment distance between a and b,
+// i.e., the Levenshtein distance with transpositions of adjacent characters
+// counted as a single edit.
+func editDistance(a, b string) int {
+	s, t := []rune(a), []rune(b)
+	// dist[i][j] is the distance between s[:i] and t[:j]
+	dist := make([][]int, len(s)+1)
+	for i := range dist {
+		dist[i] = make([]int, len(t)+1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			dist[i][j] = minInt(dist[i-1][j]+1, dist[i][j-1]+1, dist[i-1][j-1]+cost)
+			if i > 1 && j > 1 && s[i-1] == t[j-2] && s[i-2] == t[j-1] {
+				dist[i][j] = minInt(dist[i][j], dist[i-2][j-2]+1)
+			}
+		}
+	}
+	return dist[len(s)][len(t)]
+}
+
+func minInt(first int, rest ...int) int {
+	for _, n := range rest {
+		if n < first {
+			first = n
+		}
+	}
+	return first
+}
+
 // typeNameOf returns a descriptive name for the NestedText type.
-func typeNameOf(data interface{}) string {
-	switch data.(type) {
-	case string:
-		return "string"
-	case []interface{}:
+func typeNameOf(n *parse.Node) string {
+	switch n.Kind {
+	case parse.ListNode:
 		return "list"
-	case map[string]interface{}:
+	case parse.DictNode:
 		return "dict"
 	default:
-		return fmt.Sprintf("%T", data)
+		return "string"
+	}
+}
+
+// prefixErrorPath prepends path segments to the paths of a decoding error, while
+// unwinding from a nested value: prefix to its Go path, and segment, a dict key
+// or list index like "[2]", to its key path.
+func prefixErrorPath(err error, prefix, segment string) {
+	switch e := err.(type) {
+	case *UnmarshalTypeError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case *UnknownFieldError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case *RequiredFieldError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case *ArrayLengthError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case *KeyConflictError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case *ValidationError:
+		e.Path = prefix + e.Path
+		e.KeyPath = joinKeyPath(segment, e.KeyPath)
+	case ErrorList:
+		for _, err := range e {
+			prefixErrorPath(err, prefix, segment)
+		}
+	}
+}
+
+// joinKeyPath prepends segment to a key path, separating dict keys with dots:
+// "servers" and "[2].host" are joined to "servers[2].host".
+func joinKeyPath(segment, path string) string {
+	if path == "" || path[0] == '[' {
+		return segment + path
+	}
+	return segment + "." + path
+}
+
+// setErrorPosition records the position of node n in a decoding error lacking one.
+func setErrorPosition(err error, n *parse.Node) {
+	if e, ok := err.(*UnmarshalTypeError); ok && e.Line == 0 {
+		e.Line, e.Column = n.LineNo, n.ColNo
+	}
+}
+
+// errorLocation formats the position and key path of a decoding error for use in
+// its message. The position reads "file:line:col: " if the name of the input
+// source is known, "[line,col] " otherwise.
+func errorLocation(filename string, line, column int, keyPath string) (pos, at string) {
+	switch {
+	case filename != "" && line > 0:
+		pos = fmt.Sprintf("%s:%d:%d: ", filename, line, column)
+	case filename != "":
+		pos = filename + ": "
+	case line > 0:
+		pos = fmt.Sprintf("[%d,%d] ", line, column)
 	}
+	if keyPath != "" {
+		at = " at " + keyPath
+	}
+	return pos, at
 }
 
 // UnmarshalTypeError describes a type mismatch during unmarshaling.
+// The error message names the position and key path of the offending value, so
+// that it can be fixed without knowledge of the Go types involved.
 type UnmarshalTypeError struct {
-	Value string       // Description of the NestedText value
-	Type  reflect.Type // Target Go type
-	Path  string       // Path to the error (e.g., ".Config.Database.Port")
+	Value        string       // Description of the NestedText value
+	Type         reflect.Type // Target Go type
+	Path         string       // Path to the error (e.g., ".Config.Database.Port")
+	KeyPath      string       // Path to the error in document keys (e.g., "database.port")
+	Line, Column int          // Position of the value in the input
+	Filename     string       // Name of the input source, if known
+	Err          error        // Underlying conversion error, if any
 }
 
 func (e *UnmarshalTypeError) Error() string {
-	if e.Path != "" {
-		return fmt.Sprintf("nestedtext: cannot unmarshal %s into Go value of type %s at %s", e.Value, e.Type, e.Path)
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	msg := fmt.Sprintf("nestedtext: %scannot unmarshal %s into Go value of type %s%s", pos, e.Value, e.Type, at)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *UnmarshalTypeError) Unwrap() error {
+	return e.Err
+}
+
+// UnknownFieldError describes a dict key which does not match any field of the
+// target struct. It is only reported if DisallowUnknownFields is in effect.
+type UnknownFieldError struct {
+	Key          string       // The offending dict key
+	Type         reflect.Type // Target Go struct type
+	Path         string       // Path to the struct (e.g., ".Config.Database")
+	KeyPath      string       // Path to the key in document keys (e.g., "database.prot")
+	Suggestions  []string     // Closest matching keys, best match first
+	Line, Column int          // Position of the key in the input
+	Filename     string       // Name of the input source, if known
+}
+
+func (e *UnknownFieldError) Error() string {
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	msg := fmt.Sprintf("nestedtext: %sunknown key %q for Go value of type %s%s", pos, e.Key, e.Type, at)
+	if len(e.Suggestions) > 0 {
+		quoted := make([]string, len(e.Suggestions))
+		for i, s := range e.Suggestions {
+			quoted[i] = strconv.Quote(s)
+		}
+		msg += "; did you mean " + strings.Join(quoted, " or ") + "?"
+	}
+	return msg
+}
+
+// RequiredFieldError describes struct fields tagged as required which are absent
+// from the NestedText dict the struct is decoded from.
+type RequiredFieldError struct {
+	Fields       []string     // Keys of all missing fields, in struct order
+	Type         reflect.Type // Target Go struct type
+	Path         string       // Path to the struct (e.g., ".Config.Database")
+	KeyPath      string       // Path to the dict in document keys (e.g., "database")
+	Line, Column int          // Position of the enclosing dict in the input
+	Filename     string       // Name of the input source, if known
+}
+
+func (e *RequiredFieldError) Error() string {
+	quoted := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		quoted[i] = strconv.Quote(f)
+	}
+	noun := "field"
+	if len(e.Fields) > 1 {
+		noun = "fields"
+	}
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	return fmt.Sprintf("nestedtext: %smissing required %s %s for Go value of type %s%s",
+		pos, noun, strings.Join(quoted, ", "), e.Type, at)
+}
+
+// KeyConflictError describes two distinct dict keys which resolve to the same
+// struct field, such as "Port" and "port" for an untagged field Port.
+type KeyConflictError struct {
+	Key          string       // The offending dict key
+	Previous     string       // The earlier key resolving to the same field
+	Field        string       // Go field name
+	Type         reflect.Type // Target Go struct type
+	Path         string       // Path to the struct (e.g., ".Config.Database")
+	KeyPath      string       // Path to the key in document keys (e.g., "database.Port")
+	Line, Column int          // Position of the key in the input
+	Filename     string       // Name of the input source, if known
+}
+
+func (e *KeyConflictError) Error() string {
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	return fmt.Sprintf("nestedtext: %skey %q conflicts with key %q for field %s of Go value of type %s%s",
+		pos, e.Key, e.Previous, e.Field, e.Type, at)
+}
+
+// ArrayLengthError describes a NestedText list whose number of items does not
+// match the length of the target Go array.
+type ArrayLengthError struct {
+	Len          int          // Number of items in the list
+	Type         reflect.Type // Target Go array type
+	Path         string       // Path to the array (e.g., ".Config.Origin")
+	KeyPath      string       // Path to the list in document keys (e.g., "origin")
+	Line, Column int          // Position of the list in the input
+	Filename     string       // Name of the input source, if known
+}
+
+func (e *ArrayLengthError) Error() string {
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	return fmt.Sprintf("nestedtext: %scannot unmarshal list of %d items into Go array of type %s%s",
+		pos, e.Len, e.Type, at)
+}
+
+// ValidationError describes a decoded value which violates a constraint given by an
+// "ntvalidate" tag, or whose ValidateNT method reported an error.
+type ValidationError struct {
+	Constraint   string       // Violated constraint (e.g., "max=65535"), or "ValidateNT"
+	Value        string       // Description of the value
+	Type         reflect.Type // Go type of the value
+	Path         string       // Path to the value (e.g., ".Config.Port")
+	KeyPath      string       // Path to the value in document keys (e.g., "port")
+	Line, Column int          // Position of the value in the input
+	Filename     string       // Name of the input source, if known
+	Err          error        // Error returned by ValidateNT, if any
+}
+
+func (e *ValidationError) Error() string {
+	pos, at := errorLocation(e.Filename, e.Line, e.Column, e.KeyPath)
+	if e.Err != nil {
+		return fmt.Sprintf("nestedtext: %sinvalid value of type %s%s: %v", pos, e.Type, at, e.Err)
+	}
+	return fmt.Sprintf("nestedtext: %s%s violates %s%s", pos, e.Value, e.Constraint, at)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList is the error returned by decoding with CollectErrors in effect, or if
+// several values fail validation. It holds all errors encountered in document
+// order, each of them carrying its own path and position. errors.Is and errors.As
+// consider each of the errors in turn.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, err := range l {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is reports whether any of the errors in the list matches target.
+func (l ErrorList) Is(target error) bool {
+	for _, err := range l {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first error in the list that matches target, and if one is found,
+// sets target to that error value and returns true.
+func (l ErrorList) As(target interface{}) bool {
+	for _, err := range l {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// add appends err to the list, flattening nested lists.
+func (l ErrorList) add(err error) ErrorList {
+	if nested, ok := err.(ErrorList); ok {
+		return append(l, nested...)
+	}
+	return append(l, err)
+}
+
+// err returns the list as an error, its only error if it has just one, or nil if
+// it is empty.
+func (l ErrorList) err() error {
+	switch len(l) {
+	case 0:
+		return nil
+	case 1:
+		return l[0]
 	}
-	return fmt.Sprintf("nestedtext: cannot unmarshal %s into Go value of type %s", e.Value, e.Type)
+	return l
 }
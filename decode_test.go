@@ -2,9 +2,16 @@ package nestedtext
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
 	"reflect"
+	"regexp"
+	"regexp/syntax"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestUnmarshalBasicStruct(t *testing.T) {
@@ -679,3 +686,1033 @@ port: 8080
 		t.Errorf("Name = %q, want %q", config.Name, "myapp")
 	}
 }
+
+func TestUnmarshalDisallowUnknownFields(t *testing.T) {
+	input := `
+name: myapp
+database:
+    host: localhost
+    prot: 5432
+`
+	type Database struct {
+		Host string `nt:"host"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Name     string   `nt:"name"`
+		Database Database `nt:"database"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal without option failed: %v", err)
+	}
+
+	err := Unmarshal([]byte(input), &config, DisallowUnknownFields())
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) {
+		t.Fatalf("expected UnknownFieldError, got %T: %v", err, err)
+	}
+	if ufe.Key != "prot" {
+		t.Errorf("Key = %q, want %q", ufe.Key, "prot")
+	}
+	if ufe.Line != 5 || ufe.Column != 5 {
+		t.Errorf("position = [%d,%d], want [5,5]", ufe.Line, ufe.Column)
+	}
+	if !reflect.DeepEqual(ufe.Suggestions, []string{"port"}) {
+		t.Errorf("Suggestions = %v, want [port]", ufe.Suggestions)
+	}
+	if !strings.Contains(ufe.Path, "Database") {
+		t.Errorf("Path = %q, want it to name the Database struct", ufe.Path)
+	}
+	if !strings.Contains(err.Error(), `did you mean "port"?`) {
+		t.Errorf("error message lacks suggestion: %v", err)
+	}
+}
+
+func TestUnmarshalDisallowUnknownFieldsNoSuggestion(t *testing.T) {
+	type Config struct {
+		Name string `nt:"name"`
+	}
+	var config Config
+	err := Unmarshal([]byte("completely_different: x"), &config, DisallowUnknownFields())
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) {
+		t.Fatalf("expected UnknownFieldError, got %T: %v", err, err)
+	}
+	if len(ufe.Suggestions) != 0 {
+		t.Errorf("Suggestions = %v, want none", ufe.Suggestions)
+	}
+}
+
+func TestUnmarshalRequiredFields(t *testing.T) {
+	type Config struct {
+		Name  string `nt:"name,required"`
+		Port  int    `nt:"port,required"`
+		Host  string `nt:",required"`
+		Debug bool   `nt:"debug"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte("name: a\nport: 1\nHost: h\n"), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	input := `
+debug: true
+name: myapp
+`
+	err := Unmarshal([]byte(input), &config)
+	var rfe *RequiredFieldError
+	if !errors.As(err, &rfe) {
+		t.Fatalf("expected RequiredFieldError, got %T: %v", err, err)
+	}
+	if !reflect.DeepEqual(rfe.Fields, []string{"port", "Host"}) {
+		t.Errorf("Fields = %v, want [port Host]", rfe.Fields)
+	}
+	if rfe.Line != 2 || rfe.Column != 1 {
+		t.Errorf("position = [%d,%d], want [2,1]", rfe.Line, rfe.Column)
+	}
+
+	// An empty document lacks all required fields
+	for _, input := range []string{"", "# nothing here\n"} {
+		err = Unmarshal([]byte(input), &Config{})
+		if !errors.As(err, &rfe) {
+			t.Fatalf("%q: expected RequiredFieldError, got %T: %v", input, err, err)
+		}
+		if !reflect.DeepEqual(rfe.Fields, []string{"name", "port", "Host"}) {
+			t.Errorf("%q: Fields = %v, want [name port Host]", input, rfe.Fields)
+		}
+	}
+}
+
+func TestUnmarshalRequiredFieldsNested(t *testing.T) {
+	input := `
+servers:
+    -
+        host: a
+    -
+        port: 80
+`
+	type Server struct {
+		Host string `nt:"host,required"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Servers []Server `nt:"servers"`
+	}
+
+	var config Config
+	err := Unmarshal([]byte(input), &config)
+	var rfe *RequiredFieldError
+	if !errors.As(err, &rfe) {
+		t.Fatalf("expected RequiredFieldError, got %T: %v", err, err)
+	}
+	if rfe.Line != 6 || rfe.Column != 9 {
+		t.Errorf("position = [%d,%d], want [6,9]", rfe.Line, rfe.Column)
+	}
+	if !strings.Contains(rfe.Path, "[1]") {
+		t.Errorf("Path = %q, want it to contain the list index", rfe.Path)
+	}
+}
+
+func TestUnmarshalDefaults(t *testing.T) {
+	type Database struct {
+		Host string `nt:"host,default=localhost"`
+		Port int    `nt:"port,default=5432"`
+	}
+	type Config struct {
+		Name     string            `nt:"name"`
+		Port     int               `nt:"port,default=8080"`
+		Debug    bool              `nt:"debug,default=true"`
+		Ratio    float64           `nt:"ratio,default=0.5"`
+		Hosts    []string          `nt:"hosts,default=[localhost, 127.0.0.1]"`
+		Labels   map[string]string `nt:"labels,default={env: dev, tier: web}"`
+		Database Database          `nt:"database"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte("name: myapp\nport: 9090\n"), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := Config{
+		Name:     "myapp",
+		Port:     9090,
+		Debug:    true,
+		Ratio:    0.5,
+		Hosts:    []string{"localhost", "127.0.0.1"},
+		Labels:   map[string]string{"env": "dev", "tier": "web"},
+		Database: Database{Host: "localhost", Port: 5432},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("got %+v, want %+v", config, want)
+	}
+
+	var empty Config
+	if err := Unmarshal([]byte(""), &empty); err != nil {
+		t.Fatalf("Unmarshal of empty input failed: %v", err)
+	}
+	if empty.Port != 8080 || empty.Database.Port != 5432 {
+		t.Errorf("defaults not applied to empty input: %+v", empty)
+	}
+}
+
+func TestUnmarshalNestedStructDefault(t *testing.T) {
+	type Database struct {
+		Host string `nt:"host"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Database Database `nt:"database,default={host: db, port: 5432}"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte("{}"), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if config.Database != (Database{Host: "db", Port: 5432}) {
+		t.Errorf("Database = %+v, want {db 5432}", config.Database)
+	}
+}
+
+func TestUnmarshalDefaultsKeepNilEmbedded(t *testing.T) {
+	type Audit struct {
+		Created time.Time `nt:"created"`
+		Owner   string    `nt:"owner"`
+	}
+	type Tuning struct {
+		Retries int `nt:"retries,default=3"`
+	}
+	type Record struct {
+		*Audit
+		*Tuning
+		Name string `nt:"name"`
+	}
+
+	var r Record
+	if err := Unmarshal([]byte("name: a\n"), &r); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if r.Audit != nil {
+		t.Errorf("Audit = %+v, want nil", r.Audit)
+	}
+	if r.Tuning == nil || r.Tuning.Retries != 3 {
+		t.Errorf("Tuning = %+v, want default applied", r.Tuning)
+	}
+
+	r.Tuning = nil
+	data, err := Marshal(r)
+	if err != nil || string(data) != "name: a\n" {
+		t.Errorf("Marshal() = %q, %v, want %q", data, err, "name: a\n")
+	}
+}
+
+func TestUnmarshalInvalidDefault(t *testing.T) {
+	type Config struct {
+		Port int `nt:"port,default=eighty"`
+	}
+	var config Config
+	err := Unmarshal([]byte("{}"), &config)
+	var nte NestedTextError
+	if !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Fatalf("expected usage error, got %T: %v", err, err)
+	}
+}
+
+// Level demonstrates the encoding.TextUnmarshaler and encoding.TextMarshaler interfaces.
+type Level int
+
+func (l *Level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level " + string(text))
+	}
+	return nil
+}
+
+func (l Level) MarshalText() ([]byte, error) {
+	switch l {
+	case 1:
+		return []byte("low"), nil
+	case 2:
+		return []byte("high"), nil
+	}
+	return nil, errors.New("invalid level")
+}
+
+func TestUnmarshalTextUnmarshaler(t *testing.T) {
+	input := `
+addr: 192.168.1.1
+level: high
+limits:
+    low: 10
+    high: 20
+`
+	type Config struct {
+		Addr   net.IP        `nt:"addr"`
+		Level  Level         `nt:"level"`
+		Limits map[Level]int `nt:"limits"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !config.Addr.Equal(net.IPv4(192, 168, 1, 1)) {
+		t.Errorf("Addr = %v, want 192.168.1.1", config.Addr)
+	}
+	if config.Level != 2 {
+		t.Errorf("Level = %d, want 2", config.Level)
+	}
+	if !reflect.DeepEqual(config.Limits, map[Level]int{1: 10, 2: 20}) {
+		t.Errorf("Limits = %v, want map[1:10 2:20]", config.Limits)
+	}
+
+	err := Unmarshal([]byte("level: medium"), &config)
+	if err == nil || !strings.Contains(err.Error(), "unknown level") {
+		t.Errorf("expected error from UnmarshalText, got %v", err)
+	}
+
+	// Errors are located at the value or key
+	tests := []struct {
+		input   string
+		line    int
+		column  int
+		keyPath string
+	}{
+		{"addr: 1.1.1.1\nlevel: medium\n", 2, 8, "level"},
+		{"limits:\n  low: 1\n  medium: 2\n", 3, 3, "limits.medium"},
+	}
+	for _, tt := range tests {
+		err := Unmarshal([]byte(tt.input), &config, WithSourceName("cfg.nt"))
+		var typeErr *UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("%q: expected UnmarshalTypeError, got %T: %v", tt.input, err, err)
+		}
+		if typeErr.Line != tt.line || typeErr.Column != tt.column || typeErr.KeyPath != tt.keyPath ||
+			typeErr.Filename != "cfg.nt" || !strings.Contains(typeErr.Err.Error(), "unknown level") {
+			t.Errorf("%q: got %v", tt.input, err)
+		}
+	}
+}
+
+func TestUnmarshalerTakesPrecedenceOverTextUnmarshaler(t *testing.T) {
+	var v bothUnmarshalers
+	if err := Unmarshal([]byte("> x"), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v.via != "UnmarshalNT" {
+		t.Errorf("decoded via %s, want UnmarshalNT", v.via)
+	}
+}
+
+type bothUnmarshalers struct {
+	via string
+}
+
+func (b *bothUnmarshalers) UnmarshalNT(value interface{}) error {
+	b.via = "UnmarshalNT"
+	return nil
+}
+
+func (b *bothUnmarshalers) UnmarshalText(text []byte) error {
+	b.via = "UnmarshalText"
+	return nil
+}
+
+func TestUnmarshalDurationAndTime(t *testing.T) {
+	input := `
+timeout: 1m30s
+created: 2024-03-01T12:30:00Z
+day: 2024-03-02
+holidays:
+    [2024-12-25, 2024-12-26]
+`
+	type Config struct {
+		Timeout  time.Duration `nt:"timeout"`
+		Created  time.Time     `nt:"created"`
+		Day      *time.Time    `nt:"day,layout=2006-01-02"`
+		Holidays []time.Time   `nt:"holidays,layout=DateOnly"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if config.Timeout != 90*time.Second {
+		t.Errorf("Timeout = %v, want 1m30s", config.Timeout)
+	}
+	if want := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC); !config.Created.Equal(want) {
+		t.Errorf("Created = %v, want %v", config.Created, want)
+	}
+	if want := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC); config.Day == nil || !config.Day.Equal(want) {
+		t.Errorf("Day = %v, want %v", config.Day, want)
+	}
+	if len(config.Holidays) != 2 || config.Holidays[1].Day() != 26 {
+		t.Errorf("Holidays = %v, want 2024-12-25 and 2024-12-26", config.Holidays)
+	}
+
+	err := Unmarshal([]byte("timeout: 30"), &config)
+	var ute *UnmarshalTypeError
+	if !errors.As(err, &ute) || ute.Err == nil {
+		t.Errorf("expected UnmarshalTypeError for duration without unit, got %T: %v", err, err)
+	}
+	err = Unmarshal([]byte("day: 03/02/2024"), &config)
+	var parseErr *time.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Errorf("expected wrapped time.ParseError, got %T: %v", err, err)
+	}
+
+	// The layout applies to map values in both directions
+	var dates struct {
+		Dates map[string]time.Time `nt:"dates,layout=2006-01-02"`
+	}
+	input = "dates:\n  start: 2024-03-02\n"
+	if err := Unmarshal([]byte(input), &dates); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if data, err := Marshal(dates); err != nil || string(data) != input {
+		t.Errorf("Marshal() = %q, %v, want %q", data, err, input)
+	}
+}
+
+type Common struct {
+	Name    string `nt:"name"`
+	Version string `nt:"version"`
+}
+
+type Limits struct {
+	MaxConns int `nt:"max_conns"`
+}
+
+func TestUnmarshalEmbeddedStruct(t *testing.T) {
+	input := `
+name: api
+version: 1.2
+port: 8080
+max_conns: 100
+timeout: 5s
+`
+	type Timeouts struct {
+		Timeout time.Duration `nt:"timeout"`
+	}
+	type Server struct {
+		Common
+		*Limits
+		Port     int      `nt:"port"`
+		Timeouts Timeouts `nt:",inline"`
+	}
+
+	var server Server
+	if err := Unmarshal([]byte(input), &server, DisallowUnknownFields()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if server.Name != "api" || server.Version != "1.2" || server.Port != 8080 {
+		t.Errorf("got %+v", server)
+	}
+	if server.Limits == nil || server.MaxConns != 100 {
+		t.Errorf("Limits = %+v, want allocated with MaxConns 100", server.Limits)
+	}
+	if server.Timeouts.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", server.Timeouts.Timeout)
+	}
+}
+
+func TestUnmarshalEmbeddedStructConflicts(t *testing.T) {
+	type A struct {
+		Name string `nt:"name"`
+		ID   string `nt:"id"`
+	}
+	type B struct {
+		Name string `nt:"name"`
+		ID   string
+	}
+	type Outer struct {
+		A
+		B
+		Name string `nt:"name"`
+	}
+
+	input := `
+name: outer
+id: tagged
+`
+	var outer Outer
+	if err := Unmarshal([]byte(input), &outer); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	// The shallowest field wins
+	if outer.Name != "outer" || outer.A.Name != "" || outer.B.Name != "" {
+		t.Errorf("Name: got outer=%q A=%q B=%q, want only outer set", outer.Name, outer.A.Name, outer.B.Name)
+	}
+	// At equal depth, the tagged field wins
+	if outer.A.ID != "tagged" || outer.B.ID != "" {
+		t.Errorf("ID: got A=%q B=%q, want only A set", outer.A.ID, outer.B.ID)
+	}
+
+	type Ambiguous struct {
+		A
+		B2 struct {
+			Name string `nt:"name"`
+		} `nt:",inline"`
+	}
+	var ambiguous Ambiguous
+	err := Unmarshal([]byte("name: x"), &ambiguous, DisallowUnknownFields())
+	var ufe *UnknownFieldError
+	if !errors.As(err, &ufe) {
+		t.Errorf("expected ambiguous field to be unknown, got %v", err)
+	}
+}
+
+func TestUnmarshalNonStringMapKeys(t *testing.T) {
+	input := `
+tiers:
+    1: bronze
+    10: gold
+    2: silver
+weights:
+    0.5: light
+    1.5: heavy
+flags:
+    true: on
+    false: off
+`
+	type Config struct {
+		Tiers   map[int]string     `nt:"tiers"`
+		Weights map[float64]string `nt:"weights"`
+		Flags   map[bool]string    `nt:"flags"`
+	}
+
+	var config Config
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(config.Tiers, map[int]string{1: "bronze", 2: "silver", 10: "gold"}) {
+		t.Errorf("Tiers = %v", config.Tiers)
+	}
+	if !reflect.DeepEqual(config.Weights, map[float64]string{0.5: "light", 1.5: "heavy"}) {
+		t.Errorf("Weights = %v", config.Weights)
+	}
+	if !reflect.DeepEqual(config.Flags, map[bool]string{true: "on", false: "off"}) {
+		t.Errorf("Flags = %v", config.Flags)
+	}
+
+	var bad map[uint8]string
+	err := Unmarshal([]byte("300: x"), &bad)
+	var ute *UnmarshalTypeError
+	if !errors.As(err, &ute) {
+		t.Errorf("expected UnmarshalTypeError for out-of-range key, got %T: %v", err, err)
+	}
+}
+
+func TestUnmarshalArray(t *testing.T) {
+	type Shape struct {
+		Origin [3]float64 `nt:"origin"`
+		Tags   [2]string  `nt:"tags"`
+	}
+
+	var shape Shape
+	input := `
+origin:
+    - 1.5
+    - 2
+    - -3
+tags:
+    [a, b]
+`
+	if err := Unmarshal([]byte(input), &shape); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if shape.Origin != [3]float64{1.5, 2, -3} || shape.Tags != [2]string{"a", "b"} {
+		t.Errorf("got %+v", shape)
+	}
+
+	short := `
+origin:
+    - 1
+    - 2
+`
+	err := Unmarshal([]byte(short), &shape)
+	var ale *ArrayLengthError
+	if !errors.As(err, &ale) {
+		t.Fatalf("expected ArrayLengthError, got %T: %v", err, err)
+	}
+	if ale.Len != 2 || ale.Line != 3 || ale.Column != 5 || ale.Path != ".Shape.Origin" || ale.KeyPath != "origin" {
+		t.Errorf("unexpected error fields: %+v", ale)
+	}
+
+	shape = Shape{Origin: [3]float64{7, 8, 9}}
+	if err := Unmarshal([]byte(short), &shape, ZeroFillArrays()); err != nil {
+		t.Fatalf("Unmarshal with ZeroFillArrays failed: %v", err)
+	}
+	if shape.Origin != [3]float64{1, 2, 0} {
+		t.Errorf("Origin = %v, want [1 2 0]", shape.Origin)
+	}
+
+	long := "- a\n- b\n- c\n"
+	var pair [2]string
+	if err := Unmarshal([]byte(long), &pair, ZeroFillArrays()); !errors.As(err, &ale) {
+		t.Errorf("expected ArrayLengthError for long list, got %T: %v", err, err)
+	}
+}
+
+func TestUnmarshalErrorPositions(t *testing.T) {
+	type Server struct {
+		Host string `nt:"host"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Database struct {
+			Port int `nt:"port"`
+		} `nt:"database"`
+		Servers []Server `nt:"servers"`
+	}
+
+	tests := []struct {
+		name         string
+		input        string
+		keyPath      string
+		line, column int
+	}{
+		{
+			name:    "nested dict",
+			input:   "database:\n    port: abc\n",
+			keyPath: "database.port",
+			line:    2, column: 11,
+		},
+		{
+			name:    "list of dicts",
+			input:   "servers:\n    -\n        host: a\n    -\n        host: b\n        port: x\n",
+			keyPath: "servers[1].port",
+			line:    6, column: 15,
+		},
+		{
+			name:    "container mismatch",
+			input:   "servers:\n    host: a\n",
+			keyPath: "servers",
+			line:    2, column: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config Config
+			err := Unmarshal([]byte(tt.input), &config)
+			var ute *UnmarshalTypeError
+			if !errors.As(err, &ute) {
+				t.Fatalf("expected UnmarshalTypeError, got %T: %v", err, err)
+			}
+			if ute.KeyPath != tt.keyPath || ute.Line != tt.line || ute.Column != tt.column {
+				t.Errorf("got %s at [%d,%d], want %s at [%d,%d]",
+					ute.KeyPath, ute.Line, ute.Column, tt.keyPath, tt.line, tt.column)
+			}
+			want := fmt.Sprintf("[%d,%d] ", tt.line, tt.column)
+			if !strings.Contains(err.Error(), want) || !strings.HasSuffix(err.Error(), " at "+tt.keyPath) {
+				t.Errorf("Error() = %q", err.Error())
+			}
+		})
+	}
+}
+
+func TestUnmarshalCollectErrors(t *testing.T) {
+	type Server struct {
+		Host string `nt:"host,required"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Name    string   `nt:"name"`
+		Servers []Server `nt:"servers"`
+		Retries int      `nt:"retries"`
+	}
+
+	input := `
+name: prod
+servers:
+    -
+        host: a
+        port: eighty
+    -
+        port: 81
+        prto: 82
+retries: many
+`
+	var config Config
+	err := Unmarshal([]byte(input), &config, CollectErrors(), DisallowUnknownFields())
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("expected ErrorList, got %T: %v", err, err)
+	}
+	if len(list) != 4 {
+		t.Fatalf("expected 4 errors, got %d:\n%v", len(list), err)
+	}
+
+	var ute *UnmarshalTypeError
+	if !errors.As(list[0], &ute) || ute.KeyPath != "servers[0].port" {
+		t.Errorf("list[0] = %v, want type error at servers[0].port", list[0])
+	}
+	var ufe *UnknownFieldError
+	if !errors.As(list[1], &ufe) || ufe.KeyPath != "servers[1].prto" {
+		t.Errorf("list[1] = %v, want unknown key at servers[1].prto", list[1])
+	}
+	var rfe *RequiredFieldError
+	if !errors.As(list[2], &rfe) || rfe.KeyPath != "servers[1]" {
+		t.Errorf("list[2] = %v, want missing field at servers[1]", list[2])
+	}
+	if !errors.As(list[3], &ute) || ute.KeyPath != "retries" {
+		t.Errorf("list[3] = %v, want type error at retries", list[3])
+	}
+
+	// errors.As on the list finds the first matching member
+	if !errors.As(err, &rfe) || rfe.Fields[0] != "host" {
+		t.Errorf("errors.As(RequiredFieldError) failed on %v", err)
+	}
+	if config.Name != "prod" || config.Servers[1].Port != 81 {
+		t.Errorf("valid values should still be decoded, got %+v", config)
+	}
+
+	// A single error is returned as a list as well
+	err = Unmarshal([]byte("retries: x"), &config, CollectErrors())
+	if !errors.As(err, &list) || len(list) != 1 {
+		t.Errorf("expected ErrorList of 1, got %T: %v", err, err)
+	}
+	if err := Unmarshal([]byte("retries: 3"), &config, CollectErrors()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestUnmarshalBoolStrings(t *testing.T) {
+	type Features struct {
+		Cache   bool `nt:"cache"`
+		Metrics bool `nt:"metrics"`
+		Debug   bool `nt:"debug"`
+	}
+	input := "cache: Yes\nmetrics: off\ndebug: ENABLED\n"
+
+	var f Features
+	if err := Unmarshal([]byte(input), &f); err == nil {
+		t.Error("expected error with default vocabulary")
+	}
+	if err := Unmarshal([]byte(input), &f, HumanBools()); err != nil {
+		t.Fatalf("Unmarshal with HumanBools failed: %v", err)
+	}
+	if !f.Cache || f.Metrics || !f.Debug {
+		t.Errorf("got %+v", f)
+	}
+
+	f = Features{}
+	opt := BoolStrings([]string{"ja"}, []string{"nein"}, false)
+	if err := Unmarshal([]byte("cache: ja\nmetrics: nein\n"), &f, opt); err != nil || !f.Cache {
+		t.Errorf("got %+v, %v", f, err)
+	}
+	if err := Unmarshal([]byte("cache: Ja\n"), &f, opt); err == nil {
+		t.Error("expected case-sensitive match to fail")
+	}
+	if err := Unmarshal([]byte("cache: true\n"), &f, opt); err == nil {
+		t.Error("expected default spelling to be replaced")
+	}
+
+	err := Unmarshal([]byte("cache: x"), &f, BoolStrings([]string{"on"}, []string{"ON"}, true))
+	var nte NestedTextError
+	if !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Errorf("expected usage error for ambiguous spelling, got %v", err)
+	}
+}
+
+func TestUnmarshalNumericLiterals(t *testing.T) {
+	type Settings struct {
+		Mask    uint32      `nt:"mask"`
+		Mode    os.FileMode `nt:"mode"`
+		Count   int         `nt:"count"`
+		Flags   int8        `nt:"flags"`
+		Decimal int         `nt:"decimal"`
+		Padded  int         `nt:"padded"`
+	}
+	input := "mask: 0xFF_FF\nmode: 0o755\ncount: 1_000_000\nflags: -0b101\ndecimal: 0755\npadded: -08\n"
+
+	var s Settings
+	if err := Unmarshal([]byte(input), &s); err == nil {
+		t.Error("expected error without NumericLiterals")
+	}
+	if err := Unmarshal([]byte(input), &s, NumericLiterals()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := Settings{Mask: 0xFFFF, Mode: 0755, Count: 1000000, Flags: -5, Decimal: 755, Padded: -8}
+	if s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	// Numbers without a base prefix are decimal, leading zeros notwithstanding
+	for _, bad := range []string{"count: 0_755", "count: _1", "count: 1__0", "count: -", "count: 0x"} {
+		if err := Unmarshal([]byte(bad), &s, NumericLiterals()); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+	if err := Unmarshal([]byte("count: 000"), &s, NumericLiterals()); err != nil || s.Count != 0 {
+		t.Errorf("count: 000: got %d, %v", s.Count, err)
+	}
+}
+
+func TestUnmarshalByteSizes(t *testing.T) {
+	type Limits struct {
+		Buffer  int      `nt:"buffer,bytes"`
+		Upload  uint64   `nt:"upload,bytes"`
+		Plain   int      `nt:"plain,bytes"`
+		Hex     int      `nt:"hex,bytes"`
+		Caches  []uint32 `nt:"caches,bytes"`
+		NoUnits int      `nt:"no_units"`
+	}
+	input := `
+buffer: 512Ki
+upload: 10 MB
+plain: 4096
+hex: 0x1B
+caches:
+    - 1GiB
+    - 64k
+no_units: 1
+`
+	var l Limits
+	if err := Unmarshal([]byte(input), &l, NumericLiterals()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := Limits{Buffer: 512 << 10, Upload: 10e6, Plain: 4096, Hex: 0x1B, Caches: []uint32{1 << 30, 64000}, NoUnits: 1}
+	if !reflect.DeepEqual(l, want) {
+		t.Errorf("got %+v, want %+v", l, want)
+	}
+
+	for _, bad := range []string{"no_units: 1Ki", "caches:\n    - 4GiB", "buffer: 5XB", "buffer: Ki"} {
+		var ute *UnmarshalTypeError
+		if err := Unmarshal([]byte(bad), &l); !errors.As(err, &ute) {
+			t.Errorf("%q: expected UnmarshalTypeError, got %v", bad, err)
+		}
+	}
+}
+
+func TestUnmarshalKeyMatching(t *testing.T) {
+	type Server struct {
+		Host string
+		Port int `nt:"port"`
+	}
+
+	var s Server
+	if err := Unmarshal([]byte("host: a\nport: 1\n"), &s); err != nil || s.Host != "a" {
+		t.Errorf("case-insensitive match failed: %+v, %v", s, err)
+	}
+
+	err := Unmarshal([]byte("Host: a\nhost: b\n"), &s)
+	var kce *KeyConflictError
+	if !errors.As(err, &kce) {
+		t.Fatalf("expected KeyConflictError, got %T: %v", err, err)
+	}
+	if kce.Key != "host" || kce.Previous != "Host" || kce.Field != "Host" || kce.Line != 2 || kce.KeyPath != "host" {
+		t.Errorf("unexpected error fields: %+v", kce)
+	}
+
+	s = Server{}
+	if err := Unmarshal([]byte("host: a\nHost: b\nPort: 2\n"), &s, ExactKeys()); err != nil {
+		t.Fatalf("Unmarshal with ExactKeys failed: %v", err)
+	}
+	if s.Host != "b" || s.Port != 0 {
+		t.Errorf("ExactKeys: got %+v, want only Host set", s)
+	}
+
+	var ufe *UnknownFieldError
+	err = Unmarshal([]byte("host: a\n"), &s, ExactKeys(), DisallowUnknownFields())
+	if !errors.As(err, &ufe) {
+		t.Errorf("expected UnknownFieldError with ExactKeys, got %v", err)
+	}
+}
+
+func TestUnmarshalMergePolicies(t *testing.T) {
+	type Endpoint struct {
+		Host string `nt:"host"`
+		Port int    `nt:"port"`
+	}
+	type Config struct {
+		Hosts     []string            `nt:"hosts"`
+		Extra     []string            `nt:"extra,merge=append"`
+		Endpoints []Endpoint          `nt:"endpoints"`
+		Labels    map[string]string   `nt:"labels"`
+		Routes    map[string]Endpoint `nt:"routes"`
+		Main      Endpoint            `nt:"main"`
+		Backup    Endpoint            `nt:"backup,merge=replace"`
+	}
+	defaults := func() Config {
+		return Config{
+			Hosts:     []string{"a", "b", "c"},
+			Extra:     []string{"x"},
+			Endpoints: []Endpoint{{"e1", 1}, {"e2", 2}},
+			Labels:    map[string]string{"env": "dev", "team": "core"},
+			Routes:    map[string]Endpoint{"api": {"api.local", 80}},
+			Main:      Endpoint{"main.local", 80},
+			Backup:    Endpoint{"backup.local", 80},
+		}
+	}
+	input := `
+hosts:
+    - z
+extra:
+    - y
+endpoints:
+    -
+        port: 10
+labels:
+    env: prod
+routes:
+    api:
+        port: 8080
+main:
+    port: 443
+backup:
+    port: 443
+`
+
+	config := defaults()
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := Config{
+		Hosts:     []string{"z"},
+		Extra:     []string{"x", "y"},
+		Endpoints: []Endpoint{{"", 10}},
+		Labels:    map[string]string{"env": "prod", "team": "core"},
+		Routes:    map[string]Endpoint{"api": {"", 8080}},
+		Main:      Endpoint{"main.local", 443},
+		Backup:    Endpoint{"", 443},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("default policies:\ngot  %+v\nwant %+v", config, want)
+	}
+
+	config = defaults()
+	if err := Unmarshal([]byte(input), &config, MapMerge(MergeDeep)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want := map[string]Endpoint{"api": {"api.local", 8080}}; !reflect.DeepEqual(config.Routes, want) {
+		t.Errorf("MapMerge(MergeDeep): Routes = %+v, want %+v", config.Routes, want)
+	}
+
+	config = defaults()
+	err := Unmarshal([]byte(input), &config, SliceMerge(MergeDeep), MapMerge(MergeReplace))
+	if err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want = Config{
+		Hosts:     []string{"z", "b", "c"},
+		Extra:     []string{"x", "y"},
+		Endpoints: []Endpoint{{"e1", 10}, {"e2", 2}},
+		Labels:    map[string]string{"env": "prod"},
+		Routes:    map[string]Endpoint{"api": {"", 8080}},
+		Main:      Endpoint{"main.local", 443},
+		Backup:    Endpoint{"", 443},
+	}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("overridden policies:\ngot  %+v\nwant %+v", config, want)
+	}
+
+	endpoint := Endpoint{"main.local", 80}
+	if err := Unmarshal([]byte("port: 443"), &endpoint, StructMerge(MergeReplace)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if endpoint != (Endpoint{"", 443}) {
+		t.Errorf("StructMerge(MergeReplace): got %+v", endpoint)
+	}
+
+	var nte NestedTextError
+	if err := Unmarshal([]byte(input), &config, MapMerge(MergeAppend)); !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Errorf("expected usage error for appending to maps, got %v", err)
+	}
+
+	// Fields can restore the default for maps
+	var routes struct {
+		Routes  map[string]Endpoint `nt:"routes,merge=entries"`
+		Mirrors map[string]Endpoint `nt:"mirrors"`
+	}
+	routes.Routes = map[string]Endpoint{"api": {"api.local", 80}, "web": {"web.local", 80}}
+	routes.Mirrors = map[string]Endpoint{"api": {"api.local", 80}}
+	input = "routes:\n    api:\n        port: 8080\nmirrors:\n    api:\n        port: 8080\n"
+	if err := Unmarshal([]byte(input), &routes, MapMerge(MergeDeep)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want := map[string]Endpoint{"api": {"", 8080}, "web": {"web.local", 80}}; !reflect.DeepEqual(routes.Routes, want) {
+		t.Errorf("merge=entries: Routes = %+v, want %+v", routes.Routes, want)
+	}
+	if want := map[string]Endpoint{"api": {"api.local", 8080}}; !reflect.DeepEqual(routes.Mirrors, want) {
+		t.Errorf("MapMerge(MergeDeep): Mirrors = %+v, want %+v", routes.Mirrors, want)
+	}
+	if err := Unmarshal([]byte("main:\n    port: 1\n"), &config, StructMerge(MergeEntries)); !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Errorf("expected usage error for StructMerge(MergeEntries), got %v", err)
+	}
+
+	// Appending does not apply to the maps and structs within the slices
+	var pools struct {
+		Labels    []map[string]string `nt:"labels,merge=append"`
+		Endpoints []Endpoint          `nt:"endpoints,merge=append"`
+	}
+	pools.Labels = []map[string]string{{"env": "dev"}}
+	pools.Endpoints = []Endpoint{{"e1", 1}}
+	if err := Unmarshal([]byte("labels:\n    -\n        env: prod\nendpoints:\n    -\n        port: 2\n"), &pools); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(pools.Labels) != 2 || pools.Labels[1]["env"] != "prod" || len(pools.Endpoints) != 2 || pools.Endpoints[1].Port != 2 {
+		t.Errorf("merge=append on nested maps and structs: got %+v", pools)
+	}
+
+	var bad struct {
+		Hosts []string `nt:"hosts,merge=prepend"`
+	}
+	if err := Unmarshal([]byte("hosts:\n    - a\n"), &bad); !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Errorf("expected usage error for unknown merge policy, got %v", err)
+	}
+}
+
+func TestUnmarshalDecodeFunc(t *testing.T) {
+	type Config struct {
+		Endpoint url.URL        `nt:"endpoint"`
+		Filter   *regexp.Regexp `nt:"filter"`
+		Mirrors  []url.URL      `nt:"mirrors"`
+	}
+	parseURL := DecodeFunc(func(s string) (url.URL, error) {
+		u, err := url.Parse(s)
+		if err != nil {
+			return url.URL{}, err
+		}
+		return *u, nil
+	})
+	compile := DecodeFunc(regexp.Compile)
+
+	input := `
+endpoint: https://example.com/api
+filter: ^v[0-9]+$
+mirrors:
+    - https://a.example.com
+    - https://b.example.com
+`
+	var config Config
+	if err := Unmarshal([]byte(input), &config, parseURL, compile); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if config.Endpoint.Host != "example.com" || config.Endpoint.Path != "/api" {
+		t.Errorf("Endpoint = %v", config.Endpoint)
+	}
+	if config.Filter == nil || !config.Filter.MatchString("v12") {
+		t.Errorf("Filter = %v", config.Filter)
+	}
+	if len(config.Mirrors) != 2 || config.Mirrors[1].Host != "b.example.com" {
+		t.Errorf("Mirrors = %v", config.Mirrors)
+	}
+
+	err := Unmarshal([]byte("filter: (unclosed\n"), &config, compile)
+	var ute *UnmarshalTypeError
+	if !errors.As(err, &ute) || ute.KeyPath != "filter" || ute.Err == nil {
+		t.Fatalf("expected UnmarshalTypeError wrapping the conversion error, got %v", err)
+	}
+	var syntaxErr *syntax.Error
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("expected the regexp syntax error to be unwrappable from %v", err)
+	}
+}
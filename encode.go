@@ -2,16 +2,21 @@ package nestedtext
 
 import (
 	"bytes"
+	"encoding"
 	"fmt"
 	"io"
+	"io/fs"
+	"math/big"
 	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
 	defaultInlineLimit = 128
+	defaultBinaryWidth = 76 // line width of base64 in MIME, see RFC 2045
 	fastIndentMax      = 16 // size of pre-allocated space buffer
 )
 
@@ -19,12 +24,15 @@ const (
 //
 // Marshal traverses the value v recursively. If an encountered value implements
 // the Marshaler interface, Marshal calls its MarshalNT method to produce NestedText.
+// Otherwise, if it implements encoding.TextMarshaler, Marshal calls its MarshalText
+// method and encodes the result as a NestedText string.
 //
 // Otherwise, Marshal uses the following type-dependent default encodings:
 //
 // Struct values encode as NestedText dicts. Each exported struct field becomes
-// a member of the dict, using the field name as the key, unless the field is
-// omitted for one of the reasons given below.
+// a member of the dict, using the field name as the key, or the key derived from it
+// by the naming strategy set with WithFieldNaming, unless the field is omitted for
+// one of the reasons given below.
 //
 // The encoding of each struct field can be customized by the format string
 // stored under the "nt" key in the struct field's tag. The format string gives
@@ -36,18 +44,41 @@ const (
 // encoding if the field has an empty value, defined as false, 0, a nil pointer,
 // a nil interface value, and any empty array, slice, map, or string.
 //
+// The "default=value" option declares the value an absent field receives when
+// decoding. With the WithOmitDefaults option, fields equal to their default are
+// omitted from the encoding.
+//
 // As a special case, if the field tag is "-", the field is always omitted.
 //
-// Map keys must be strings; the map keys are sorted and used as dict keys.
+// Following encoding/json, the fields of embedded structs are promoted into the
+// enclosing dict, as are the fields of struct fields with the "inline" option.
+// If several promoted fields map to the same key, the least nested one wins,
+// then a tagged one; otherwise all of them are omitted.
+//
+// Map keys must be strings, integers, floating point numbers, booleans, or implement
+// encoding.TextMarshaler. They are converted to strings like leaf values, sorted by
+// their natural order (numerically for numbers), and used as dict keys.
 //
-// Slice and array values encode as NestedText lists.
+// Slice and array values encode as NestedText lists, except byte slices, which
+// encode as base64 strings, wrapped into multi-line strings at the width set by
+// WithBinaryWidth. The "hex" and "base64url" tag options select hexadecimal or
+// URL-safe base64 instead.
 //
 // String values encode as NestedText strings.
 //
 // Integer and floating point values encode as NestedText strings containing
 // the decimal representation of the number.
 //
-// Boolean values encode as the strings "true" or "false".
+// Complex values encode like "1+2i". big.Int, big.Float and big.Rat values encode
+// as their exact decimal representation, such as "19.99"; rationals without a finite
+// decimal representation encode as fractions, such as "1/3".
+//
+// Boolean values encode as the strings "true" or "false", unless other strings are
+// chosen with WithBoolStrings.
+//
+// time.Duration values encode as returned by their String method. time.Time values
+// encode in RFC 3339 format, or using the layout given by the "layout=" option of
+// the field's tag.
 func Marshal(v interface{}, opts ...EncodeOption) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := NewEncoder(&buf, opts...)
@@ -59,11 +90,32 @@ func Marshal(v interface{}, opts ...EncodeOption) ([]byte, error) {
 
 // Encoder writes NestedText values to an output stream.
 type Encoder struct {
-	w           io.Writer
-	opts        []EncodeOption
-	indentSize  int
-	inlineLimit int
-	minimalMode bool
+	w            io.Writer
+	opts         []EncodeOption
+	indentSize   int
+	inlineLimit  int
+	minimalMode  bool
+	omitDefaults bool
+	trueString   string
+	falseString  string
+
+	octalFileModes bool
+	byteUnits      bool
+	binaryWidth    int
+	naming         NamingStrategy
+	structInfos    structInfos // struct metadata, if naming is not comparable
+
+	flattenSeparator string // set by WithFlattenKeys
+	flattenDepth     int
+
+	converters map[reflect.Type]func(reflect.Value) (interface{}, error)
+	fallback   func(interface{}) (interface{}, error)
+}
+
+// structInfo returns the metadata of struct type t under the encoder's naming
+// strategy. The strategy is the same for every call, as the options are.
+func (enc *Encoder) structInfo(t reflect.Type) *structInfo {
+	return getStructInfo(t, enc.naming, &enc.structInfos)
 }
 
 // EncodeOption configures the behavior of the encoding process.
@@ -117,6 +169,124 @@ func WithMinimal() EncodeOption {
 	}
 }
 
+// WithOmitDefaults returns an option that omits struct fields whose value equals
+// the default declared with the "default=" option of their "nt" tag. Decoding the
+// output restores these fields from their defaults.
+func WithOmitDefaults() EncodeOption {
+	return func(enc *Encoder) error {
+		enc.omitDefaults = true
+		return nil
+	}
+}
+
+// WithBoolStrings returns an option that sets the strings boolean values encode
+// as. The default is "true" and "false". The strings must be distinct, non-empty,
+// and fit on a single line.
+func WithBoolStrings(trueString, falseString string) EncodeOption {
+	return func(enc *Encoder) error {
+		if trueString == "" || falseString == "" || trueString == falseString ||
+			strings.ContainsAny(trueString+falseString, "\r\n") {
+			return makeNestedTextError(ErrCodeUsage,
+				fmt.Sprintf("invalid boolean strings %q and %q", trueString, falseString))
+		}
+		enc.trueString = trueString
+		enc.falseString = falseString
+		return nil
+	}
+}
+
+// WithOctalFileModes returns an option that encodes fs.FileMode (and os.FileMode)
+// values as octal numbers, such as "0o755", rather than in the symbolic notation
+// returned by their String method, which cannot be decoded. Decoding octal numbers
+// requires the NumericLiterals option.
+func WithOctalFileModes() EncodeOption {
+	return func(enc *Encoder) error {
+		enc.octalFileModes = true
+		return nil
+	}
+}
+
+// WithByteUnits returns an option that encodes non-negative integer fields tagged
+// "bytes" as sizes with the largest fitting unit, such as "512KiB" or "10MB".
+// Sizes which are not a multiple of a kilobyte are encoded as plain numbers.
+func WithByteUnits() EncodeOption {
+	return func(enc *Encoder) error {
+		enc.byteUnits = true
+		return nil
+	}
+}
+
+// WithBinaryWidth returns an option that sets the line width at which the text
+// encoding of byte slices is wrapped into a multi-line string. Set to 0 to disable
+// wrapping. The default is 76.
+func WithBinaryWidth(width int) EncodeOption {
+	return func(enc *Encoder) error {
+		if width < 0 {
+			width = 0
+		}
+		enc.binaryWidth = width
+		return nil
+	}
+}
+
+// WithFieldNaming returns an option that derives the keys of struct fields without
+// a tag name from their Go field names using strategy, such as SnakeCase or
+// SpaceSeparated. Pass the same strategy to FieldNaming when decoding.
+func WithFieldNaming(strategy NamingStrategy) EncodeOption {
+	return func(enc *Encoder) error {
+		enc.naming = strategy
+		return nil
+	}
+}
+
+// WithFlattenKeys returns an option that writes the entries of nested dicts as
+// keys of an enclosing dict, joining the keys with separator, as in
+// "database.host: db1". Dicts at indentation level depth or deeper are flattened
+// completely: with depth 0, the top-level dict holds all keys; with depth 1, its
+// values are flat dicts. Empty dicts are kept as values. This is the counterpart of
+// the ExpandKeys decode option.
+func WithFlattenKeys(separator string, depth int) EncodeOption {
+	return func(enc *Encoder) error {
+		if separator == "" {
+			return makeNestedTextError(ErrCodeUsage, "WithFlattenKeys requires a non-empty separator")
+		}
+		if depth < 0 {
+			depth = 0
+		}
+		enc.flattenSeparator = separator
+		enc.flattenDepth = depth
+		return nil
+	}
+}
+
+// WithEncodeFunc returns an option that registers fn to encode values of type T,
+// such as types from other packages, which cannot be given a MarshalNT method.
+// The value returned by fn is encoded in place of the original one; it will usually
+// be a string, but may be a list or dict as well. For map keys, it must be a
+// string. Registered functions take precedence over all other ways of encoding a
+// type.
+func WithEncodeFunc[T any](fn func(T) (interface{}, error)) EncodeOption {
+	return func(enc *Encoder) error {
+		if enc.converters == nil {
+			enc.converters = make(map[reflect.Type]func(reflect.Value) (interface{}, error))
+		}
+		enc.converters[reflect.TypeOf((*T)(nil)).Elem()] = func(v reflect.Value) (interface{}, error) {
+			return fn(v.Interface().(T))
+		}
+		return nil
+	}
+}
+
+// WithFallback returns an option that sets a hook for values of types the encoder
+// cannot handle, such as channels or functions. The value returned by fn is encoded
+// in place of the original one. Without a fallback, such values result in an error.
+func WithFallback(fn func(v interface{}) (interface{}, error)) EncodeOption {
+	return func(enc *Encoder) error {
+		enc.fallback = fn
+		return nil
+	}
+}
+
 // NewEncoder returns a new encoder that writes to w.
 func NewEncoder(w io.Writer, opts ...EncodeOption) *Encoder {
 	return &Encoder{
@@ -124,6 +294,9 @@ func NewEncoder(w io.Writer, opts ...EncodeOption) *Encoder {
 		opts:        opts,
 		indentSize:  2,
 		inlineLimit: defaultInlineLimit,
+		trueString:  "true",
+		falseString: "false",
+		binaryWidth: defaultBinaryWidth,
 	}
 }
 
@@ -154,13 +327,15 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 		return bcnt, err
 	}
 
-	// Check for Marshaler interface
-	if m, ok := tree.(Marshaler); ok {
-		v, marshalErr := m.MarshalNT()
+	// Check for Marshaler and encoding.TextMarshaler interfaces
+	if marshaled, ok, marshalErr := enc.marshalItem(tree); ok {
 		if marshalErr != nil {
 			return bcnt, marshalErr
 		}
-		return enc.encode(indent, v, bcnt, nil)
+		return enc.encode(indent, marshaled, bcnt, nil)
+	}
+	if c, ok := tree.(converted); ok {
+		tree = c.value
 	}
 
 	if !isEncodable(tree) {
@@ -170,7 +345,7 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 	switch t := tree.(type) {
 	// We first try a couple of standard-cases without relying on reflection
 	case string:
-		if ok, s := isInlineable(encAsString, t); ok {
+		if ok, s := enc.isInlineable(encAsString, t); ok {
 			bcnt, err = enc.indent(bcnt, err, indent)
 			bcnt, err = enc.wr(bcnt, err, []byte("> "))
 			bcnt, err = enc.wr(bcnt, err, s)
@@ -191,7 +366,7 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 			S := make([][]byte, len(t))
 			for i, item := range t { // measure all list items
 				l += len(item)
-				ok, s := isInlineable(encAsList, item)
+				ok, s := enc.isInlineable(encAsList, item)
 				inlineable = inlineable && ok
 				if !inlineable || l > enc.inlineLimit {
 					break // stop trying if not suited for inlining
@@ -246,9 +421,13 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 		}
 	case []interface{}:
 		for _, item := range t {
+			item, _, marshalErr := enc.marshalItem(item)
+			if marshalErr != nil {
+				return bcnt, marshalErr
+			}
 			bcnt, err = enc.indent(bcnt, err, indent)
 			bcnt, err = enc.wr(bcnt, err, []byte("-"))
-			if ok, itemAsBytes := isInlineable(encAsList, item); ok {
+			if ok, itemAsBytes := enc.isInlineable(encAsList, item); ok {
 				bcnt, err = enc.wr(bcnt, err, []byte{' '})
 				bcnt, err = enc.wr(bcnt, err, itemAsBytes)
 				bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
@@ -260,11 +439,7 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 	case bool:
 		bcnt, err = enc.indent(bcnt, err, indent)
 		bcnt, err = enc.wr(bcnt, err, []byte("> "))
-		if t {
-			bcnt, err = enc.wr(bcnt, err, []byte("true"))
-		} else {
-			bcnt, err = enc.wr(bcnt, err, []byte("false"))
-		}
+		bcnt, err = enc.wr(bcnt, err, []byte(enc.boolString(t)))
 		bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
 	case int, int8, int16, int32, int64:
 		bcnt, err = enc.indent(bcnt, err, indent)
@@ -281,6 +456,12 @@ func (enc *Encoder) encode(indent int, tree interface{}, bcnt int, err error) (i
 		bcnt, err = enc.wr(bcnt, err, []byte("> "))
 		bcnt, err = enc.wr(bcnt, err, []byte(fmt.Sprintf("%v", t)))
 		bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
+	case *OrderedMap:
+		if t != nil {
+			bcnt, err = enc.encodeOrderedMap(indent, t, bcnt, err)
+		}
+	case OrderedMap:
+		bcnt, err = enc.encodeOrderedMap(indent, &t, bcnt, err)
 	default:
 		bcnt, err = enc.encodeReflected(indent, tree, bcnt, err)
 	}
@@ -299,10 +480,13 @@ func (enc *Encoder) encodeReflected(indent int, tree interface{}, bcnt int, err
 	case reflect.Slice, reflect.Array:
 		l := v.Len()
 		for i := 0; i < l; i++ {
-			item := v.Index(i).Interface()
+			item, _, marshalErr := enc.marshalItem(v.Index(i).Interface())
+			if marshalErr != nil {
+				return bcnt, marshalErr
+			}
 			bcnt, err = enc.indent(bcnt, err, indent)
 			bcnt, err = enc.wr(bcnt, err, []byte{'-'})
-			if ok, itemAsBytes := isInlineable(encAsList, item); ok {
+			if ok, itemAsBytes := enc.isInlineable(encAsList, item); ok {
 				bcnt, err = enc.wr(bcnt, err, []byte{' '})
 				bcnt, err = enc.wr(bcnt, err, itemAsBytes)
 				bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
@@ -312,94 +496,101 @@ func (enc *Encoder) encodeReflected(indent int, tree interface{}, bcnt int, err
 			}
 		}
 	case reflect.Map:
-		keys := v.MapKeys()
-		// special case: empty map
-		if len(keys) == 0 {
-			return enc.wr(bcnt, err, []byte("{}\n"))
-		}
-		// first sort items alphabetically by key
-		sort.Slice(keys, func(i, j int) bool {
-			return keys[i].String() < keys[j].String()
-		})
-		for _, k := range keys {
-			if k.Kind() != reflect.String {
-				return 0, makeNestedTextError(ErrCodeSchema,
-					"map key is not a string; can only encode keys of type string")
-			}
-			key := k.Interface().(string)
-			item := v.MapIndex(k).Interface()
-			if ok, keyAsBytes := isInlineable(encAsKey, key); ok {
-				bcnt, err = enc.indent(bcnt, err, indent)
-				bcnt, err = enc.wr(bcnt, err, keyAsBytes)
-				bcnt, err = enc.wr(bcnt, err, []byte{':'})
-				if ok, itemAsBytes := isInlineable(encAsString, item); ok {
-					bcnt, err = enc.wr(bcnt, err, []byte{' '})
-					bcnt, err = enc.wr(bcnt, err, itemAsBytes)
-					bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-				} else {
-					bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-					bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
-				}
-			} else { // output key as a multi-line key
-			if enc.minimalMode {
-				return 0, makeNestedTextError(ErrCodeSchema,
-					"map key contains newline; multi-line keys are not allowed in minimal mode")
-			}
-			S := strings.Split(key, "\n")
-				for _, s := range S {
-					bcnt, err = enc.indent(bcnt, err, indent)
-					if s == "" {
-						bcnt, err = enc.wr(bcnt, err, []byte(":"))
-					} else {
-						bcnt, err = enc.wr(bcnt, err, []byte(": "))
-						bcnt, err = enc.wr(bcnt, err, []byte(s))
-					}
-					bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-				}
-				bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
-			}
+		entries, entriesErr := enc.mapEntries(v)
+		if entriesErr != nil {
+			return bcnt, entriesErr
 		}
+		bcnt, err = enc.encodeDict(indent, entries, bcnt, err)
 	case reflect.Struct:
 		bcnt, err = enc.encodeStruct(indent, v, bcnt, err)
 	default:
-		err = makeNestedTextError(ErrCodeSchema,
-			fmt.Sprintf("unable to encode type %T", tree))
+		if enc.fallback == nil {
+			return bcnt, makeNestedTextError(ErrCodeSchema,
+				fmt.Sprintf("unable to encode type %T", tree))
+		}
+		replacement, fallbackErr := enc.callFallback(tree)
+		if fallbackErr != nil {
+			return bcnt, fallbackErr
+		}
+		bcnt, err = enc.encode(indent, replacement, bcnt, err)
+	}
+	return bcnt, err
+}
+
+// encodeDictEntry encodes e as an entry of a NestedText dict.
+func (enc *Encoder) encodeDictEntry(indent int, e dictEntry, bcnt int, err error) (int, error) {
+	key, item := e.key, e.item
+	if ok, keyAsBytes := enc.isInlineable(encAsKey, key); ok {
+		bcnt, err = enc.indent(bcnt, err, indent)
+		bcnt, err = enc.wr(bcnt, err, keyAsBytes)
+		bcnt, err = enc.wr(bcnt, err, []byte{':'})
+		if ok, itemAsBytes := enc.isInlineable(encAsString, item); ok {
+			bcnt, err = enc.wr(bcnt, err, []byte{' '})
+			bcnt, err = enc.wr(bcnt, err, itemAsBytes)
+			bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
+		} else {
+			bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
+			bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
+		}
+	} else { // output key as a multi-line key
+		if enc.minimalMode {
+			what := "map key"
+			if e.field {
+				what = "struct field name"
+			}
+			return 0, makeNestedTextError(ErrCodeSchema,
+				what+" contains newline; multi-line keys are not allowed in minimal mode")
+		}
+		S := strings.Split(key, "\n")
+		for _, s := range S {
+			bcnt, err = enc.indent(bcnt, err, indent)
+			if s == "" {
+				bcnt, err = enc.wr(bcnt, err, []byte(":"))
+			} else {
+				bcnt, err = enc.wr(bcnt, err, []byte(": "))
+				bcnt, err = enc.wr(bcnt, err, []byte(s))
+			}
+			bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
+		}
+		bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
 	}
 	return bcnt, err
 }
 
 // encodeStruct encodes a struct value as a NestedText dict.
 func (enc *Encoder) encodeStruct(indent int, v reflect.Value, bcnt int, err error) (int, error) {
-	t := v.Type()
+	entries, entriesErr := enc.structEntries(v)
+	if entriesErr != nil {
+		return bcnt, entriesErr
+	}
+	return enc.encodeDict(indent, entries, bcnt, err)
+}
+
+// structEntries returns the dict entries a struct value encodes as, sorted by key.
+func (enc *Encoder) structEntries(v reflect.Value) ([]dictEntry, error) {
+	info := enc.structInfo(v.Type())
 
 	type fieldEntry struct {
 		name  string
 		value reflect.Value
+		fi    *fieldInfo
 	}
-	fields := make([]fieldEntry, 0, t.NumField())
+	fields := make([]fieldEntry, 0, len(info.fields))
 
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if !field.IsExported() {
-			continue
+	for i := range info.fields {
+		fi := &info.fields[i]
+		fieldValue, ok := lookupField(v, fi.index)
+		if !ok {
+			continue // field of a nil embedded struct pointer
 		}
-
-		fieldValue := v.Field(i)
-		name := field.Name
-
-		tagOpts := parseNTTag(field.Tag.Get("nt"))
-		if tagOpts.ignore {
-			continue // skip this field
-		}
-		if tagOpts.name != "" {
-			name = tagOpts.name
+		if fi.omitEmpty && isEmptyValue(fieldValue) {
+			continue
 		}
-
-		if tagOpts.omitEmpty && isEmptyValue(fieldValue) {
+		if enc.omitDefaults && enc.isDefaultValue(fi, fieldValue) {
 			continue
 		}
 
-		fields = append(fields, fieldEntry{name: name, value: fieldValue})
+		fields = append(fields, fieldEntry{name: fi.key(), value: fieldValue, fi: fi})
 	}
 
 	// Sort fields by name for consistent output
@@ -407,43 +598,75 @@ func (enc *Encoder) encodeStruct(indent int, v reflect.Value, bcnt int, err erro
 		return fields[i].name < fields[j].name
 	})
 
-	// Empty struct
-	if len(fields) == 0 {
-		return enc.wr(bcnt, err, []byte("{}\n"))
+	entries := make([]dictEntry, len(fields))
+	for i, f := range fields {
+		item := f.value.Interface()
+		if f.fi.layout != "" {
+			item = formatTimes(f.value, f.fi.layout)
+		} else if f.fi.bytes && enc.byteUnits {
+			item = formatByteSizes(f.value)
+		} else if f.fi.binary != "" {
+			item = enc.formatBinaries(f.value, f.fi.binary)
+		}
+		item, _, err := enc.marshalItem(item)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = dictEntry{key: f.name, item: item, field: true}
 	}
+	return entries, nil
+}
 
-	for _, f := range fields {
-		item := f.value.Interface()
-		if ok, keyAsBytes := isInlineable(encAsKey, f.name); ok {
-			bcnt, err = enc.indent(bcnt, err, indent)
-			bcnt, err = enc.wr(bcnt, err, keyAsBytes)
-			bcnt, err = enc.wr(bcnt, err, []byte{':'})
-			if ok, itemAsBytes := isInlineable(encAsString, item); ok {
-				bcnt, err = enc.wr(bcnt, err, []byte{' '})
-				bcnt, err = enc.wr(bcnt, err, itemAsBytes)
-				bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-			} else {
-				bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-				bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
-			}
-		} else {
-			// Multi-line key (rare for struct field names)
-			if enc.minimalMode {
-				return 0, makeNestedTextError(ErrCodeSchema,
-					"struct field name contains newline; multi-line keys are not allowed in minimal mode")
-			}
-			S := strings.Split(f.name, "\n")
-			for _, s := range S {
-				bcnt, err = enc.indent(bcnt, err, indent)
-				if s == "" {
-					bcnt, err = enc.wr(bcnt, err, []byte(":"))
-				} else {
-					bcnt, err = enc.wr(bcnt, err, []byte(": "))
-					bcnt, err = enc.wr(bcnt, err, []byte(s))
-				}
-				bcnt, err = enc.wr(bcnt, err, []byte{'\n'})
-			}
-			bcnt, err = enc.encodeIfNotEmpty(item, indent, bcnt, err)
+// mapEntries returns the dict entries a map value encodes as, sorted by the
+// natural order of their keys.
+func (enc *Encoder) mapEntries(v reflect.Value) ([]dictEntry, error) {
+	keys := v.MapKeys()
+	// convert keys to strings, then sort items by key
+	sorted := make([]mapEntry, len(keys))
+	for i, k := range keys {
+		key, err := enc.mapKeyString(k)
+		if err != nil {
+			return nil, err
+		}
+		sorted[i] = mapEntry{key: key, k: k}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].less(sorted[j])
+	})
+	entries := make([]dictEntry, len(sorted))
+	for i, e := range sorted {
+		item, _, err := enc.marshalItem(v.MapIndex(e.k).Interface())
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = dictEntry{key: e.key, item: item}
+	}
+	return entries, nil
+}
+
+// dictEntry is an entry of a dict to be encoded, with its value already marshaled.
+type dictEntry struct {
+	key   string
+	item  interface{}
+	field bool // entry of a struct rather than a map
+}
+
+// encodeDict encodes entries as a NestedText dict, flattening nested dicts if
+// WithFlattenKeys applies at this level.
+func (enc *Encoder) encodeDict(indent int, entries []dictEntry, bcnt int, err error) (int, error) {
+	if len(entries) == 0 {
+		bcnt, err = enc.indent(bcnt, err, indent)
+		return enc.wr(bcnt, err, []byte("{}\n"))
+	}
+	if enc.flattenSeparator != "" && indent >= enc.flattenDepth {
+		var flattenErr error
+		if entries, flattenErr = enc.flattenKeys(entries); flattenErr != nil {
+			return bcnt, flattenErr
+		}
+	}
+	for _, e := range entries {
+		if bcnt, err = enc.encodeDictEntry(indent, e, bcnt, err); err != nil {
+			return bcnt, err
 		}
 	}
 	return bcnt, err
@@ -467,11 +690,58 @@ func isEmptyValue(v reflect.Value) bool {
 	return false
 }
 
+// isDefaultValue reports whether v equals the default value declared for field fi.
+func (enc *Encoder) isDefaultValue(fi *fieldInfo, v reflect.Value) bool {
+	if !fi.hasDefault || fi.defaultErr != nil {
+		return false
+	}
+	def, ok := enc.decodeDefault(fi)
+	return ok && reflect.DeepEqual(def, v.Interface())
+}
+
+// decodedDefault is the default value of a field, if it could be decoded.
+type decodedDefault struct {
+	value interface{}
+	ok    bool
+}
+
+// decodeDefault returns the default value declared for field fi, decoded with the
+// decoding options matching the encoder's: its naming strategy, its boolean
+// strings in addition to the default ones, and NumericLiterals, which accepts any
+// integer a decoder does. The result is cached on fi, whose naming strategy is
+// the encoder's, for each pair of boolean strings.
+func (enc *Encoder) decodeDefault(fi *fieldInfo) (interface{}, bool) {
+	key := [2]string{enc.trueString, enc.falseString}
+	if cached, ok := fi.decoded.Load(key); ok {
+		def := cached.(decodedDefault)
+		return def.value, def.ok
+	}
+	d := &Decoder{
+		naming:          enc.naming,
+		structInfos:     enc.structInfos,
+		numericLiterals: true,
+		bools: &boolVocabulary{
+			trueStrings:  append([]string{enc.trueString}, defaultBoolVocabulary.trueStrings...),
+			falseStrings: append([]string{enc.falseString}, defaultBoolVocabulary.falseStrings...),
+		},
+	}
+	v := reflect.New(fi.fieldType).Elem()
+	err := d.decode(fi.defaultNode, v, fi)
+	enc.structInfos = d.structInfos
+	def := decodedDefault{value: v.Interface(), ok: err == nil}
+	fi.decoded.Store(key, def)
+	return def.value, def.ok
+}
+
 func (enc *Encoder) encodeIfNotEmpty(item interface{}, indent, bcnt int, err error) (int, error) {
 	if err != nil {
 		return bcnt, err
 	}
-	if s, ok := item.(string); ok {
+	value := item
+	if c, ok := item.(converted); ok {
+		value = c.value
+	}
+	if s, ok := value.(string); ok {
 		if s == "" {
 			return bcnt, err
 		}
@@ -479,6 +749,277 @@ func (enc *Encoder) encodeIfNotEmpty(item interface{}, indent, bcnt int, err err
 	return enc.encode(indent+1, item, bcnt, err)
 }
 
+// converted holds a value returned by an encoder function for its own type, which
+// is encoded without applying the function again.
+type converted struct {
+	value interface{}
+}
+
+// marshalItem resolves values implementing Marshaler or encoding.TextMarshaler, as
+// well as time.Duration, time.Time, arbitrary-precision and complex numbers, byte
+// slices, and fs.FileMode values if octal file modes are enabled, into their
+// NestedText representation. Encoder functions registered with WithEncodeFunc take
+// precedence, then Marshaler. Values of unencodable types are replaced by the
+// fallback hook, if one is set. It reports whether item has been converted; other
+// values and nil pointers are returned unchanged.
+func (enc *Encoder) marshalItem(item interface{}) (interface{}, bool, error) {
+	applyConverter := true
+	if c, ok := item.(converted); ok {
+		item, applyConverter = c.value, false
+	}
+	v := reflect.ValueOf(item)
+	if v.Kind() == reflect.Pointer && v.IsNil() {
+		return item, false, nil
+	}
+	if v.IsValid() && applyConverter {
+		if conv, ok := enc.converters[v.Type()]; ok {
+			result, err := conv(v)
+			if err == nil && reflect.TypeOf(result) == v.Type() {
+				// Keep the function from being applied to its own result again
+				return converted{result}, true, nil
+			}
+			return result, true, err
+		}
+	}
+	if enc.fallback != nil && !isEncodable(item) {
+		replacement, err := enc.callFallback(item)
+		return replacement, true, err
+	}
+	switch m := item.(type) {
+	case Marshaler:
+		marshaled, err := m.MarshalNT()
+		return marshaled, true, err
+	case time.Duration:
+		return m.String(), true, nil
+	case time.Time:
+		return m.Format(time.RFC3339Nano), true, nil
+	case big.Int:
+		return m.String(), true, nil
+	case *big.Int:
+		return m.String(), true, nil
+	case big.Float:
+		return formatBigFloat(&m), true, nil
+	case *big.Float:
+		return formatBigFloat(m), true, nil
+	case big.Rat:
+		return formatBigRat(&m), true, nil
+	case *big.Rat:
+		return formatBigRat(m), true, nil
+	case complex64:
+		return formatComplex(complex128(m), 64), true, nil
+	case complex128:
+		return formatComplex(m, 128), true, nil
+	case fs.FileMode:
+		if enc.octalFileModes {
+			return fmt.Sprintf("0o%o", uint32(m)), true, nil
+		}
+	case encoding.TextMarshaler:
+		text, err := m.MarshalText()
+		return string(text), true, err
+	}
+	if v.IsValid() && isByteSlice(v.Type()) && !v.IsNil() {
+		return formatBinary(v.Bytes(), binaryBase64, enc.binaryWidth), true, nil
+	}
+	if !applyConverter {
+		return converted{item}, false, nil
+	}
+	return item, false, nil
+}
+
+// callFallback replaces item with the value returned by the fallback hook. It is an
+// error for the hook to return a value of the same type.
+func (enc *Encoder) callFallback(item interface{}) (interface{}, error) {
+	replacement, err := enc.fallback(item)
+	if err == nil && item != nil && reflect.TypeOf(replacement) == reflect.TypeOf(item) {
+		err = makeNestedTextError(ErrCodeSchema,
+			fmt.Sprintf("unable to encode type %T; fallback returned the same type", item))
+	}
+	return replacement, err
+}
+
+// formatElements formats v with format if match reports true for its type.
+// Pointers to matching values are formatted as their target, and lists and dicts
+// of them, other than byte slices and arrays, element-wise. Other values are
+// returned unchanged.
+func formatElements(v reflect.Value, match func(reflect.Type) bool, format func(reflect.Value) interface{}) interface{} {
+	switch {
+	case match(v.Type()):
+		return format(v)
+	case !containsType(v.Type(), match):
+	case v.Kind() == reflect.Pointer:
+		if !v.IsNil() {
+			return formatElements(v.Elem(), match, format)
+		}
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		list := make([]interface{}, v.Len())
+		for i := range list {
+			list[i] = formatElements(v.Index(i), match, format)
+		}
+		return list
+	case v.Kind() == reflect.Map:
+		if v.IsNil() {
+			break
+		}
+		dict := reflect.MakeMapWithSize(reflect.MapOf(v.Type().Key(), interfaceType), v.Len())
+		for iter := v.MapRange(); iter.Next(); {
+			dict.SetMapIndex(iter.Key(), reflect.ValueOf(formatElements(iter.Value(), match, format)))
+		}
+		return dict.Interface()
+	}
+	return v.Interface()
+}
+
+// containsType reports whether values of type t hold values of a type matched by
+// match, possibly nested within pointers, lists and dicts other than byte slices
+// and arrays.
+func containsType(t reflect.Type, match func(reflect.Type) bool) bool {
+	seen := map[reflect.Type]bool{} // guards against recursive types
+	for !seen[t] {
+		seen[t] = true
+		switch {
+		case match(t):
+			return true
+		case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+			if t.Elem().Kind() == reflect.Uint8 {
+				return false
+			}
+		case t.Kind() != reflect.Pointer && t.Kind() != reflect.Map:
+			return false
+		}
+		t = t.Elem()
+	}
+	return false
+}
+
+// formatTimes formats the time.Time values in v with the given layout.
+func formatTimes(v reflect.Value, layout string) interface{} {
+	return formatElements(v,
+		func(t reflect.Type) bool { return t == timeType },
+		func(v reflect.Value) interface{} { return v.Interface().(time.Time).Format(layout) })
+}
+
+// formatByteSizes formats the non-negative integers in v as sizes with a unit.
+func formatByteSizes(v reflect.Value) interface{} {
+	return formatElements(v, isIntegerType, func(v reflect.Value) interface{} {
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() < 0 {
+				return v.Interface()
+			}
+			return formatByteSize(uint64(v.Int()))
+		}
+		return formatByteSize(v.Uint())
+	})
+}
+
+// isIntegerType reports whether t is a signed or unsigned integer type.
+func isIntegerType(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// formatByteSize formats size using the largest unit that divides it, preferring
+// binary units (KiB, MiB, ...) over decimal ones (kB, MB, ...) of similar magnitude.
+func formatByteSize(size uint64) string {
+	if size == 0 {
+		return "0"
+	}
+	units := []struct {
+		suffix string
+		factor uint64
+	}{
+		{"EiB", 1 << 60}, {"EB", 1e18}, {"PiB", 1 << 50}, {"PB", 1e15}, {"TiB", 1 << 40}, {"TB", 1e12},
+		{"GiB", 1 << 30}, {"GB", 1e9}, {"MiB", 1 << 20}, {"MB", 1e6}, {"KiB", 1 << 10}, {"kB", 1e3},
+	}
+	for _, u := range units {
+		if size%u.factor == 0 {
+			return strconv.FormatUint(size/u.factor, 10) + u.suffix
+		}
+	}
+	return strconv.FormatUint(size, 10)
+}
+
+// mapEntry holds a map key together with its dict key representation.
+type mapEntry struct {
+	key string        // dict key
+	k   reflect.Value // map key
+}
+
+// less orders map entries by the natural order of their keys: numerically for
+// numbers, false before true for booleans, and lexically by dict key otherwise.
+func (e mapEntry) less(other mapEntry) bool {
+	a, b := e.k, other.k
+	if a.Kind() == reflect.Interface {
+		a, b = a.Elem(), b.Elem()
+	}
+	if a.Kind() == b.Kind() && !a.Type().Implements(textMarshalerType) {
+		switch a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return a.Int() < b.Int()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return a.Uint() < b.Uint()
+		case reflect.Float32, reflect.Float64:
+			return a.Float() < b.Float()
+		case reflect.Bool:
+			return !a.Bool() && b.Bool()
+		}
+	}
+	return e.key < other.key
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// mapKeyString converts a map key into a dict key. Encoder functions registered
+// with WithEncodeFunc take precedence, and must return a string or a value of the
+// key's own type. Keys implementing encoding.TextMarshaler are converted with
+// MarshalText, numbers and booleans are formatted like leaf values.
+func (enc *Encoder) mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.Interface && !k.IsNil() {
+		k = k.Elem()
+	}
+	if conv, ok := enc.converters[k.Type()]; ok {
+		result, err := conv(k)
+		if err != nil {
+			return "", err
+		}
+		if s, ok := result.(string); ok {
+			return s, nil
+		}
+		if reflect.TypeOf(result) != k.Type() {
+			return "", makeNestedTextError(ErrCodeSchema,
+				fmt.Sprintf("unable to encode map key of type %s; encoder function returned %T rather than a string", k.Type(), result))
+		}
+		k = reflect.ValueOf(result) // encoded like keys without a function
+	}
+	if m, ok := k.Interface().(encoding.TextMarshaler); ok {
+		if k.Kind() == reflect.Pointer && k.IsNil() {
+			return "", nil
+		}
+		text, err := m.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.String:
+		return k.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(k.Float(), 'g', -1, k.Type().Bits()), nil
+	case reflect.Bool:
+		return enc.boolString(k.Bool()), nil
+	}
+	return "", makeNestedTextError(ErrCodeSchema,
+		fmt.Sprintf("unable to encode map key of type %s; keys must be strings, numbers, booleans, or implement encoding.TextMarshaler", k.Type()))
+}
+
 func isEncodable(item interface{}) bool {
 	switch reflect.ValueOf(item).Kind() {
 	case reflect.Chan, reflect.Func, reflect.Invalid, reflect.Uintptr, reflect.UnsafePointer:
@@ -504,12 +1045,28 @@ var encItemPattern = []string{
 	"{},:\n", // Dict
 }
 
-func isInlineable(what int, item interface{}) (bool, []byte) {
-	switch reflect.ValueOf(item).Kind() {
+// boolString returns the string b encodes as.
+func (enc *Encoder) boolString(b bool) string {
+	if b {
+		return enc.trueString
+	}
+	return enc.falseString
+}
+
+func (enc *Encoder) isInlineable(what int, item interface{}) (bool, []byte) {
+	if c, ok := item.(converted); ok {
+		item = c.value
+	}
+	switch v := reflect.ValueOf(item); v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return false, nil
+		}
+		return enc.isInlineable(what, v.Elem().Interface())
 	case reflect.Array, reflect.Chan, reflect.Map, reflect.Slice, reflect.Struct:
 		return false, nil
 	case reflect.String:
-		s := item.(string)
+		s := v.String()
 		if s == "" {
 			return false, nil
 		}
@@ -518,10 +1075,11 @@ func isInlineable(what int, item interface{}) (bool, []byte) {
 		}
 		return true, []byte(s)
 	case reflect.Bool:
-		if item.(bool) {
-			return true, []byte("true")
+		s := enc.boolString(reflect.ValueOf(item).Bool())
+		if strings.ContainsAny(s, encItemPattern[what]) {
+			return false, nil
 		}
-		return true, []byte("false")
+		return true, []byte(s)
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
 		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
 		reflect.Float32, reflect.Float64:
@@ -569,4 +1127,3 @@ func (enc *Encoder) wr(bcnt int, err error, data []byte) (int, error) {
 	}
 	return bcnt + c, err
 }
-
@@ -1,8 +1,15 @@
 package nestedtext
 
 import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestEncoderOptions(t *testing.T) {
@@ -278,10 +285,10 @@ func TestMarshalUnmarshalRoundTrip(t *testing.T) {
 
 // ----------------------------------------------------------------------
 
-func expectEncode(t *testing.T, tree interface{}, target string) {
+func expectEncode(t *testing.T, tree interface{}, target string, opts ...EncodeOption) {
 	t.Helper()
 	out := &strings.Builder{}
-	NewEncoder(out).Encode(tree)
+	NewEncoder(out, opts...).Encode(tree)
 	str := out.String()
 	t.Logf("encoded:\n%s", str)
 	S := strings.Split(str, "\n")
@@ -369,3 +376,293 @@ func TestWithMinimalAcceptsNormalKeys(t *testing.T) {
 		t.Errorf("got %q, want %q", string(result), expected)
 	}
 }
+
+func TestEncodeStructOmitDefaults(t *testing.T) {
+	type Config struct {
+		Name  string   `nt:"name"`
+		Port  int      `nt:"port,default=8080"`
+		Hosts []string `nt:"hosts,default=[localhost]"`
+		Debug bool     `nt:"debug,default=false"`
+	}
+
+	config := Config{Name: "myapp", Port: 8080, Hosts: []string{"localhost"}, Debug: true}
+	expectEncode(t, config, `debug: true
+hosts:
+  [localhost]
+name: myapp
+port: 8080
+`)
+	expectEncode(t, config, `debug: true
+name: myapp
+`, WithOmitDefaults())
+}
+
+func TestEncodeOmitDefaultsMatchesOptions(t *testing.T) {
+	type Limits struct {
+		MaxConns int `nt:",default=5"`
+	}
+	type Config struct {
+		Verbose bool   `nt:"verbose,default=yes"`
+		Mode    int    `nt:"mode,default=0o644"`
+		Size    int    `nt:"size,default=1_000"`
+		Limits  Limits `nt:"limits,default={max_conns: 10}"`
+	}
+
+	config := Config{Verbose: true, Mode: 0o644, Size: 1000, Limits: Limits{MaxConns: 10}}
+	opts := []EncodeOption{WithOmitDefaults(), WithBoolStrings("yes", "no"), WithFieldNaming(SnakeCase)}
+	for i := 0; i < 2; i++ { // the second run uses the cached defaults
+		expectEncode(t, config, "{}\n", opts...)
+	}
+	// The default of Verbose does not decode with the default boolean strings
+	expectEncode(t, config, "verbose: true\n", WithOmitDefaults(), WithFieldNaming(SnakeCase))
+
+	config.Limits.MaxConns = 20
+	expectEncode(t, config, `limits:
+  max_conns: 20
+`, opts...)
+}
+
+func TestEncodeTextMarshaler(t *testing.T) {
+	type Config struct {
+		Addr   net.IP        `nt:"addr"`
+		Level  Level         `nt:"level"`
+		Limits map[Level]int `nt:"limits"`
+	}
+
+	config := Config{
+		Addr:   net.IPv4(10, 0, 0, 1),
+		Level:  1,
+		Limits: map[Level]int{1: 10, 2: 20},
+	}
+	expectEncode(t, config, `addr: 10.0.0.1
+level: low
+limits:
+  high: 20
+  low: 10
+`)
+}
+
+func TestEncodeDurationAndTime(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration `nt:"timeout"`
+		Created  time.Time     `nt:"created"`
+		Day      time.Time     `nt:"day,layout=2006-01-02"`
+		Holidays []time.Time   `nt:"holidays,layout=DateOnly"`
+	}
+
+	config := Config{
+		Timeout: 90 * time.Second,
+		Created: time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Day:     time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+		Holidays: []time.Time{
+			time.Date(2024, 12, 25, 0, 0, 0, 0, time.UTC),
+			time.Date(2024, 12, 26, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	expectEncode(t, config, `created: 2024-03-01T12:30:00Z
+day: 2024-03-02
+holidays:
+  - 2024-12-25
+  - 2024-12-26
+timeout: 1m30s
+`)
+	expectEncode(t, 2*time.Hour, "> 2h0m0s\n")
+}
+
+func TestEncodeEmbeddedStruct(t *testing.T) {
+	type Server struct {
+		Common
+		*Limits
+		Port int `nt:"port"`
+	}
+
+	expectEncode(t, Server{Common: Common{Name: "api", Version: "1.2"}, Port: 8080}, `name: api
+port: 8080
+version: 1.2
+`)
+	expectEncode(t, Server{Limits: &Limits{MaxConns: 10}, Port: 8080}, `max_conns: 10
+name:
+port: 8080
+version:
+`)
+}
+
+func TestEncodeNonStringMapKeys(t *testing.T) {
+	expectEncode(t, map[int]string{10: "gold", 2: "silver", -1: "none"}, `-1: none
+2: silver
+10: gold
+`)
+	expectEncode(t, map[float64]int{1.5: 2, 0.25: 1}, `0.25: 1
+1.5: 2
+`)
+	expectEncode(t, map[bool]string{true: "on", false: "off"}, `false: off
+true: on
+`)
+	expectEncode(t, map[Level]int{2: 20, 1: 10}, `high: 20
+low: 10
+`)
+}
+
+func TestEncodeBoolStrings(t *testing.T) {
+	type Features struct {
+		Cache bool `nt:"cache"`
+		Debug bool `nt:"debug"`
+	}
+	expectEncode(t, Features{Cache: true}, "cache: yes\ndebug: no\n", WithBoolStrings("yes", "no"))
+	expectEncode(t, []bool{true, false}, "- on\n- off\n", WithBoolStrings("on", "off"))
+	expectEncode(t, map[bool]int{true: 1, false: 0}, "no: 0\nyes: 1\n", WithBoolStrings("yes", "no"))
+
+	if _, err := Marshal(true, WithBoolStrings("same", "same")); err == nil {
+		t.Error("expected error for identical bool strings")
+	}
+}
+
+func TestEncodeOctalFileModesAndByteUnits(t *testing.T) {
+	type Settings struct {
+		Mode   os.FileMode `nt:"mode"`
+		Buffer int         `nt:"buffer,bytes"`
+		Upload uint64      `nt:"upload,bytes"`
+		Odd    int         `nt:"odd,bytes"`
+		Count  int         `nt:"count"`
+	}
+	s := Settings{Mode: 0755, Buffer: 512 << 10, Upload: 10e6, Odd: 1500, Count: 2048}
+
+	expectEncode(t, s, `buffer: 524288
+count: 2048
+mode: -rwxr-xr-x
+odd: 1500
+upload: 10000000
+`)
+	expectEncode(t, s, `buffer: 512KiB
+count: 2048
+mode: 0o755
+odd: 1500
+upload: 10MB
+`, WithOctalFileModes(), WithByteUnits())
+
+	// The output round-trips
+	data, err := Marshal(s, WithOctalFileModes(), WithByteUnits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Settings
+	if err := Unmarshal(data, &decoded, NumericLiterals()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded != s {
+		t.Errorf("round trip: got %+v, want %+v", decoded, s)
+	}
+}
+
+func TestEncodeFuncAndFallback(t *testing.T) {
+	type ID [4]byte
+	type Config struct {
+		Endpoint url.URL `nt:"endpoint"`
+		IDs      []ID    `nt:"ids"`
+	}
+	u, _ := url.Parse("https://example.com/api")
+	config := Config{Endpoint: *u, IDs: []ID{{0xde, 0xad, 0xbe, 0xef}}}
+
+	expectEncode(t, config, `endpoint: https://example.com/api
+ids:
+  - deadbeef
+`,
+		WithEncodeFunc(func(u url.URL) (interface{}, error) { return u.String(), nil }),
+		WithEncodeFunc(func(id ID) (interface{}, error) { return hex.EncodeToString(id[:]), nil }))
+
+	events := map[string]interface{}{"ready": make(chan int)}
+	if _, err := Marshal(events); err == nil {
+		t.Error("expected error encoding a channel")
+	}
+	expectEncode(t, events, "ready: <chan int>\n", WithFallback(func(v interface{}) (interface{}, error) {
+		return fmt.Sprintf("<%T>", v), nil
+	}))
+	if _, err := Marshal(events, WithFallback(func(v interface{}) (interface{}, error) { return v, nil })); err == nil {
+		t.Error("expected error for fallback returning the same type")
+	}
+
+	// Functions returning their own type are applied once
+	type Secret string
+	type Login struct {
+		User     string `nt:"user"`
+		Password Secret `nt:"password"`
+	}
+	expectEncode(t, Login{User: "kate", Password: "hunter2"}, "password: ***\nuser: kate\n",
+		WithEncodeFunc(func(s Secret) (interface{}, error) { return Secret("***"), nil }))
+	expectEncode(t, []interface{}{[]string{"a"}}, "-\n  [x, a]\n",
+		WithEncodeFunc(func(s []string) (interface{}, error) { return append([]string{"x"}, s...), nil }))
+
+	// Empty results are skipped like empty strings
+	expectEncode(t, map[string]Secret{"token": "abc"}, "token:\n",
+		WithEncodeFunc(func(s Secret) (interface{}, error) { return Secret(""), nil }))
+
+	// The fallback also applies to types unknown to the encoder
+	type Port int
+	expectEncode(t, Port(5), "> 5\n", WithFallback(func(v interface{}) (interface{}, error) {
+		return fmt.Sprint(v), nil
+	}))
+}
+
+func TestEncodeFormatTagsOnMapValues(t *testing.T) {
+	type Archive struct {
+		Dates  map[string]time.Time   `nt:"dates,layout=2006-01-02"`
+		Hashes map[string][]byte      `nt:"hashes,hex"`
+		Limits map[string]int64       `nt:"limits,bytes"`
+		Nested map[string][]time.Time `nt:"nested,layout=2006-01-02"`
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	archive := Archive{
+		Dates:  map[string]time.Time{"created": day},
+		Hashes: map[string][]byte{"sha": {0xde, 0xad}},
+		Limits: map[string]int64{"disk": 4 << 30},
+		Nested: map[string][]time.Time{"holidays": {day}},
+	}
+	expectEncode(t, archive, `dates:
+  created: 2024-03-01
+hashes:
+  sha: dead
+limits:
+  disk: 4GiB
+nested:
+  holidays:
+    - 2024-03-01
+`, WithByteUnits())
+
+	data, err := Marshal(archive, WithByteUnits())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Archive
+	if err := Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, archive) {
+		t.Errorf("round trip: got %+v, want %+v", decoded, archive)
+	}
+}
+
+func TestEncodeFuncMapKeys(t *testing.T) {
+	type Cell struct{ Row, Col int }
+	sheet := map[Cell]string{{1, 2}: "a", {3, 4}: "b"}
+	format := WithEncodeFunc(func(c Cell) (interface{}, error) { return fmt.Sprintf("%d/%d", c.Row, c.Col), nil })
+	expectEncode(t, sheet, "1/2: a\n3/4: b\n", format)
+
+	data, err := Marshal(sheet, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[Cell]string
+	err = Unmarshal(data, &decoded, DecodeFunc(func(s string) (Cell, error) {
+		var c Cell
+		_, err := fmt.Sscanf(s, "%d/%d", &c.Row, &c.Col)
+		return c, err
+	}))
+	if err != nil || !reflect.DeepEqual(decoded, sheet) {
+		t.Errorf("round trip = %v, %v", decoded, err)
+	}
+
+	// Keys must encode as strings
+	if _, err := Marshal(sheet, WithEncodeFunc(func(c Cell) (interface{}, error) { return []int{c.Row}, nil })); err == nil {
+		t.Error("expected error for a key encoded as a list")
+	}
+}
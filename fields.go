@@ -0,0 +1,274 @@
+package nestedtext
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// structInfo holds cached metadata about a struct type.
+type structInfo struct {
+	fields      []fieldInfo
+	hasDefaults bool // some field, possibly of a nested struct, has a default
+}
+
+// fieldInfo holds metadata about a single struct field.
+type fieldInfo struct {
+	name      string       // Go field name
+	index     []int        // index sequence, see reflect.Value.FieldByIndex
+	tag       string       // nt tag name (empty if not specified)
+	derived   string       // key derived by the naming strategy, for untagged fields
+	aliases   []string     // alternative keys given by the alias option
+	omitEmpty bool         // omitempty option
+	required  bool         // required option
+	layout    string       // time layout option
+	bytes     bool         // bytes option: integers are sizes with units
+	binary    string       // hex or base64url option: encoding of byte slices
+	merge     string       // merge policy option
+	fieldType reflect.Type // field type
+
+	hasDefault   bool        // default option
+	defaultValue string      // default value as given in the tag
+	defaultNode  *parse.Node // parsed default value
+	defaultErr   error       // error encountered parsing the default value
+	decoded      *sync.Map   // default value decoded for encoders, by boolean strings
+
+	constraints   []constraint // constraints given by the ntvalidate tag
+	constraintErr error        // error encountered parsing the ntvalidate tag
+}
+
+// structInfoKey identifies the metadata of a struct type under a naming strategy.
+type structInfoKey struct {
+	typ    reflect.Type
+	naming NamingStrategy
+}
+
+// structInfoCache caches struct metadata to avoid repeated reflection.
+var structInfoCache sync.Map // map[structInfoKey]*structInfo
+
+// structInfos caches struct metadata for a naming strategy which is not comparable,
+// and thus cannot be part of a structInfoKey. Decoders and encoders hold one for
+// their strategy.
+type structInfos map[reflect.Type]*structInfo
+
+// getStructInfo returns struct metadata for the given type, deriving the keys of
+// untagged fields with naming, if non-nil. The metadata is cached in
+// structInfoCache, or in local if naming is not comparable.
+func getStructInfo(t reflect.Type, naming NamingStrategy, local *structInfos) *structInfo {
+	cacheable := naming == nil || reflect.TypeOf(naming).Comparable()
+	key := structInfoKey{typ: t, naming: naming}
+	if cacheable {
+		if cached, ok := structInfoCache.Load(key); ok {
+			return cached.(*structInfo)
+		}
+	} else if cached, ok := (*local)[t]; ok {
+		return cached
+	}
+
+	info := &structInfo{
+		fields: typeFields(t, naming),
+	}
+	for i := range info.fields {
+		fi := &info.fields[i]
+		if fi.hasDefault || (fi.fieldType.Kind() == reflect.Struct && getStructInfo(fi.fieldType, naming, local).hasDefaults) {
+			info.hasDefaults = true
+			break
+		}
+	}
+
+	if cacheable {
+		structInfoCache.Store(key, info)
+	} else {
+		if *local == nil {
+			*local = make(structInfos)
+		}
+		(*local)[t] = info
+	}
+	return info
+}
+
+// typeFields returns the fields of struct type t which take part in encoding and
+// decoding, ordered by their index sequence. Fields tagged with "-" are left out.
+//
+// The fields of embedded structs are promoted, as are the fields of struct fields
+// carrying the "inline" tag option, unless the embedded struct is given a name by
+// its tag. Following encoding/json, if several fields map to the same key, the
+// least nested one wins. If there are several of these, a tagged one wins over
+// untagged ones. Otherwise, all of them are left out.
+func typeFields(t reflect.Type, naming NamingStrategy) []fieldInfo {
+	type pending struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	var fields []fieldInfo
+	visited := map[reflect.Type]bool{}
+	next := []pending{{typ: t}}
+	for len(next) > 0 {
+		current := next
+		next = nil
+		for _, p := range current {
+			if visited[p.typ] {
+				continue
+			}
+			visited[p.typ] = true
+
+			for i := 0; i < p.typ.NumField(); i++ {
+				field := p.typ.Field(i)
+				tagOpts := parseNTTag(field.Tag.Get("nt"))
+				if tagOpts.ignore {
+					continue
+				}
+
+				ft := field.Type
+				if ft.Kind() == reflect.Pointer {
+					ft = ft.Elem()
+				}
+				if field.Anonymous {
+					// Unexported embedded structs may still promote their exported
+					// fields, unless they would have to be allocated.
+					if !field.IsExported() && (ft.Kind() != reflect.Struct || field.Type.Kind() == reflect.Pointer) {
+						continue
+					}
+				} else if !field.IsExported() {
+					continue
+				}
+
+				index := make([]int, len(p.index)+1)
+				copy(index, p.index)
+				index[len(p.index)] = i
+
+				promote := (field.Anonymous && tagOpts.name == "") || tagOpts.inline
+				if promote && ft.Kind() == reflect.Struct {
+					next = append(next, pending{typ: ft, index: index})
+					continue
+				}
+				if !field.IsExported() {
+					continue
+				}
+				fields = append(fields, makeFieldInfo(field, index, tagOpts, naming))
+			}
+		}
+	}
+
+	// Resolve conflicts between fields mapping to the same key
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].key() != fields[j].key() {
+			return fields[i].key() < fields[j].key()
+		}
+		if len(fields[i].index) != len(fields[j].index) {
+			return len(fields[i].index) < len(fields[j].index)
+		}
+		return fields[i].tag != "" && fields[j].tag == ""
+	})
+	dominant := fields[:0]
+	for i := 0; i < len(fields); {
+		j := i + 1
+		for j < len(fields) && fields[j].key() == fields[i].key() {
+			j++
+		}
+		group := fields[i:j]
+		if len(group) == 1 || len(group[0].index) < len(group[1].index) ||
+			(group[0].tag != "") != (group[1].tag != "") {
+			dominant = append(dominant, group[0])
+		}
+		i = j
+	}
+	fields = dominant
+
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i].index, fields[j].index
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	return fields
+}
+
+// makeFieldInfo creates the metadata for a struct field from its tag options and
+// the naming strategy, if non-nil.
+func makeFieldInfo(field reflect.StructField, index []int, tagOpts ntTagOptions, naming NamingStrategy) fieldInfo {
+	fi := fieldInfo{
+		name:      field.Name,
+		index:     index,
+		tag:       tagOpts.name,
+		aliases:   tagOpts.aliases,
+		omitEmpty: tagOpts.omitEmpty,
+		required:  tagOpts.required,
+		layout:    tagOpts.layout,
+		bytes:     tagOpts.bytes,
+		binary:    tagOpts.binary,
+		merge:     tagOpts.merge,
+		fieldType: field.Type,
+	}
+	if naming != nil && fi.tag == "" {
+		fi.derived = naming.FieldKey(field.Name)
+	}
+	fi.constraints, fi.constraintErr = parseConstraints(field.Tag.Get("ntvalidate"), field.Type)
+	if tagOpts.hasDefault {
+		fi.hasDefault = true
+		fi.defaultValue = tagOpts.defaultValue
+		fi.defaultNode, fi.defaultErr = parseDefaultValue(tagOpts.defaultValue)
+		fi.decoded = new(sync.Map)
+	}
+	return fi
+}
+
+// key returns the dict key a field is expected under: its tag name, if present,
+// otherwise the key derived by the naming strategy, if any, otherwise its Go
+// field name.
+func (fi *fieldInfo) key() string {
+	if fi.tag != "" {
+		return fi.tag
+	}
+	if fi.derived != "" {
+		return fi.derived
+	}
+	return fi.name
+}
+
+// timeLayout returns the layout for time values of a field, which defaults to
+// RFC 3339. It is safe to call on a nil fieldInfo.
+func (fi *fieldInfo) timeLayout() string {
+	if fi == nil || fi.layout == "" {
+		return time.RFC3339Nano
+	}
+	return fi.layout
+}
+
+// fieldByIndex returns the field of struct v with the given index sequence.
+// Nil pointers to embedded structs on the way are allocated.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// lookupField returns the field of struct v with the given index sequence.
+// It reports false if the field is unreachable due to a nil pointer to an
+// embedded struct.
+func lookupField(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
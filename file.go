@@ -0,0 +1,117 @@
+package nestedtext
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// ParseFile reads and parses the NestedText file at path, like Parse. Errors carry
+// path as their Filename, unless another name is given with WithSourceName.
+func ParseFile(path string, opts ...DecodeOption) (interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, openError(path, err)
+	}
+	defer f.Close()
+	return Parse(f, append([]DecodeOption{WithSourceName(path)}, opts...)...)
+}
+
+// ParseFS reads and parses the NestedText file name within fsys, like Parse.
+// Errors carry name as their Filename, unless another name is given with
+// WithSourceName.
+func ParseFS(fsys fs.FS, name string, opts ...DecodeOption) (interface{}, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, openError(name, err)
+	}
+	defer f.Close()
+	return Parse(f, append([]DecodeOption{WithSourceName(name)}, opts...)...)
+}
+
+// UnmarshalFile reads the NestedText file at path and stores the result in the value
+// pointed to by v, like Unmarshal. Errors carry path as their Filename, unless
+// another name is given with WithSourceName.
+func UnmarshalFile(path string, v interface{}, opts ...DecodeOption) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return openError(path, err)
+	}
+	defer f.Close()
+	return decodeFrom(f, path, v, opts)
+}
+
+// UnmarshalFS reads the NestedText file name within fsys and stores the result in
+// the value pointed to by v, like Unmarshal. Errors carry name as their Filename,
+// unless another name is given with WithSourceName.
+func UnmarshalFS(fsys fs.FS, name string, v interface{}, opts ...DecodeOption) error {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return openError(name, err)
+	}
+	defer f.Close()
+	return decodeFrom(f, name, v, opts)
+}
+
+// decodeFrom decodes the input source r, named name, into v.
+func decodeFrom(r io.Reader, name string, v interface{}, opts []DecodeOption) error {
+	d := NewDecoder(r, append([]DecodeOption{WithSourceName(name)}, opts...)...)
+	return d.Decode(v)
+}
+
+// openError wraps an error opening the input source name.
+func openError(name string, err error) error {
+	reason := err
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		reason = pathErr.Err // the path is part of the message already
+	}
+	e := wrapError(ErrCodeIO, "cannot open input: "+reason.Error(), err)
+	e.Filename = name
+	return e
+}
+
+// withSourceName sets the Filename of decoding errors lacking one to name. Errors of
+// other types are returned unchanged.
+func withSourceName(err error, name string) error {
+	if name == "" {
+		return err
+	}
+	switch e := err.(type) {
+	case NestedTextError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+		return e
+	case *UnmarshalTypeError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case *UnknownFieldError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case *RequiredFieldError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case *ArrayLengthError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case *KeyConflictError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case *ValidationError:
+		if e.Filename == "" {
+			e.Filename = name
+		}
+	case ErrorList:
+		for i := range e {
+			e[i] = withSourceName(e[i], name)
+		}
+	}
+	return err
+}
@@ -0,0 +1,162 @@
+package nestedtext
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestUnmarshalFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cfg.nt")
+	if err := os.WriteFile(path, []byte("name: app\nport: 80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Name string `nt:"name"`
+		Port int    `nt:"port"`
+	}
+	if err := UnmarshalFile(path, &config); err != nil {
+		t.Fatalf("UnmarshalFile failed: %v", err)
+	}
+	if config.Name != "app" || config.Port != 80 {
+		t.Errorf("got %+v", config)
+	}
+
+	result, err := ParseFile(path)
+	if err != nil {
+		t.Fatalf("ParseFile failed: %v", err)
+	}
+	want := map[string]interface{}{"name": "app", "port": "80"}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("ParseFile() = %#v, want %#v", result, want)
+	}
+
+	if err := os.WriteFile(path, []byte("name: app\nport: eighty\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = UnmarshalFile(path, &config)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Fatalf("expected UnmarshalTypeError, got %T: %v", err, err)
+	}
+	if typeErr.Filename != path || !strings.Contains(err.Error(), path+":2:7: ") {
+		t.Errorf("error = %q, Filename = %q", err.Error(), typeErr.Filename)
+	}
+}
+
+func TestParseFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bad.nt")
+	if err := os.WriteFile(path, []byte("a: 1\n  b: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := ParseFile(path)
+	var ntErr NestedTextError
+	if !errors.As(err, &ntErr) {
+		t.Fatalf("expected NestedTextError, got %T: %v", err, err)
+	}
+	if ntErr.Filename != path || ntErr.Line != 2 || !strings.HasPrefix(err.Error(), path+":2:") {
+		t.Errorf("error = %q, Filename = %q", err.Error(), ntErr.Filename)
+	}
+
+	// Errors opening the file
+	missing := filepath.Join(dir, "missing.nt")
+	_, err = ParseFile(missing)
+	if !errors.As(err, &ntErr) || ntErr.Code != ErrCodeIO || !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected I/O error, got %T: %v", err, err)
+	}
+	if want := missing + ": cannot open input: "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error = %q, want prefix %q", err.Error(), want)
+	}
+
+	// WithSourceName overrides the file name
+	_, err = ParseFile(path, WithSourceName("settings"))
+	if err == nil || !strings.HasPrefix(err.Error(), "settings:2:") {
+		t.Errorf("error = %v, want source name settings", err)
+	}
+}
+
+func TestUnmarshalFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"conf/app.nt": {Data: []byte("- a\n- b\n")},
+		"conf/bad.nt": {Data: []byte("- a\nb: c\n")},
+	}
+
+	var list []string
+	if err := UnmarshalFS(fsys, "conf/app.nt", &list); err != nil {
+		t.Fatalf("UnmarshalFS failed: %v", err)
+	}
+	if !reflect.DeepEqual(list, []string{"a", "b"}) {
+		t.Errorf("got %v", list)
+	}
+
+	result, err := ParseFS(fsys, "conf/app.nt")
+	if err != nil || !reflect.DeepEqual(result, []interface{}{"a", "b"}) {
+		t.Errorf("ParseFS() = %#v, %v", result, err)
+	}
+
+	_, err = ParseFS(fsys, "conf/bad.nt")
+	if err == nil || !strings.HasPrefix(err.Error(), "conf/bad.nt:2:") {
+		t.Errorf("error = %v, want position in conf/bad.nt", err)
+	}
+
+	err = UnmarshalFS(fsys, "conf/none.nt", &list)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+}
+
+func TestWithSourceName(t *testing.T) {
+	type record struct {
+		Count int `nt:"count"`
+	}
+	dec := NewDecoder(strings.NewReader("count: many\n"), WithSourceName("input.nt"))
+	var r record
+	err := dec.Decode(&r)
+	if err == nil || !strings.Contains(err.Error(), "input.nt:1:8: ") {
+		t.Errorf("Decode error = %v", err)
+	}
+
+	// Errors collected in a list carry the source name, too
+	type pair struct {
+		A int `nt:"a"`
+		B int `nt:"b"`
+	}
+	var p pair
+	err = Unmarshal([]byte("a: x\nb: y\n"), &p, CollectErrors(), WithSourceName("pair.nt"))
+	var list ErrorList
+	if !errors.As(err, &list) || len(list) != 2 {
+		t.Fatalf("expected ErrorList of 2, got %T: %v", err, err)
+	}
+	for _, e := range list {
+		if !strings.Contains(e.Error(), "pair.nt:") {
+			t.Errorf("error = %q, want source name", e.Error())
+		}
+	}
+
+	// Token errors
+	dec = NewDecoder(strings.NewReader("- a\n  - b\n"), WithSourceName("tokens.nt"))
+	for {
+		_, err = dec.Token()
+		if err != nil {
+			break
+		}
+	}
+	if err == io.EOF || !strings.HasPrefix(err.Error(), "tokens.nt:2:") {
+		t.Errorf("Token error = %v", err)
+	}
+
+	// Without a source name, the position format is unchanged
+	err = Unmarshal([]byte("count: many\n"), &r)
+	if err == nil || !strings.Contains(err.Error(), "[1,8] ") {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package nestedtext
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// expandKeys returns a copy of the node hierarchy n in which dict keys containing
+// separator are expanded into nested dicts: "database.host: db1" becomes a dict
+// "database" holding the key "host". Dicts resulting from several keys are merged.
+// Keys with empty parts, such as "a..b", are kept as they are. A key which would
+// have to hold both a dict and another value, or two values, results in a format
+// error located at the later key.
+func expandKeys(n *parse.Node, separator string) (*parse.Node, error) {
+	if n == nil || n.Kind == parse.StringNode {
+		return n, nil
+	}
+	expanded := &parse.Node{Kind: n.Kind, LineNo: n.LineNo, ColNo: n.ColNo}
+	if n.Kind == parse.ListNode {
+		expanded.Items = make([]*parse.Node, len(n.Items))
+		for i, item := range n.Items {
+			var err error
+			if expanded.Items[i], err = expandKeys(item, separator); err != nil {
+				return nil, err
+			}
+		}
+		return expanded, nil
+	}
+
+	origins := make(map[*parse.Node]*parse.Node) // value -> key it was given under
+	for i, item := range n.Items {
+		key := n.Keys[i]
+		value, err := expandKeys(item, separator)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(key.Value, separator)
+		for _, part := range parts {
+			if part == "" {
+				parts = []string{key.Value}
+				break
+			}
+		}
+
+		// Descend into the dicts named by the leading parts, creating them as needed
+		dict := expanded
+		for _, part := range parts[:len(parts)-1] {
+			child := lookupKey(dict, part)
+			if child == nil {
+				child = &parse.Node{Kind: parse.DictNode, LineNo: key.LineNo, ColNo: key.ColNo}
+				origins[child] = key
+				addKey(dict, part, key, child)
+			} else if child.Kind != parse.DictNode {
+				return nil, keyConflict(key, origins[child])
+			}
+			dict = child
+		}
+		if err := mergeKey(dict, parts[len(parts)-1], key, value, origins); err != nil {
+			return nil, err
+		}
+	}
+	return expanded, nil
+}
+
+// mergeKey adds value under name to dict. If name is present already, both values
+// must be dicts, which are merged.
+func mergeKey(dict *parse.Node, name string, key, value *parse.Node, origins map[*parse.Node]*parse.Node) error {
+	existing := lookupKey(dict, name)
+	if existing == nil {
+		origins[value] = key
+		addKey(dict, name, key, value)
+		return nil
+	}
+	if existing.Kind != parse.DictNode || value.Kind != parse.DictNode {
+		return keyConflict(key, origins[existing])
+	}
+	for i, item := range value.Items {
+		if err := mergeKey(existing, value.Keys[i].Value, key, item, origins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookupKey returns the value of dict under name, or nil.
+func lookupKey(dict *parse.Node, name string) *parse.Node {
+	for i, k := range dict.Keys {
+		if k.Value == name {
+			return dict.Items[i]
+		}
+	}
+	return nil
+}
+
+// addKey adds value under name to dict, located at the position of key.
+func addKey(dict *parse.Node, name string, key, value *parse.Node) {
+	dict.Keys = append(dict.Keys, parse.NewStringNode(name, key.LineNo, key.ColNo))
+	dict.Items = append(dict.Items, value)
+}
+
+func keyConflict(key, previous *parse.Node) error {
+	err := makeNestedTextError(ErrCodeFormat,
+		fmt.Sprintf("key %q conflicts with key %q in line %d", key.Value, previous.Value, previous.LineNo))
+	err.Line, err.Column = key.LineNo, key.ColNo
+	return err
+}
+
+// flattenKeys flattens entries with flattenEntries. It is an error for two keys to
+// be flattened into the same one, such as a key "a.b" and a key "b" nested under a
+// key "a", as they could not be expanded again.
+func (enc *Encoder) flattenKeys(entries []dictEntry) ([]dictEntry, error) {
+	flat, err := enc.flattenEntries(entries)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool, len(flat))
+	for _, e := range flat {
+		if seen[e.key] {
+			return nil, makeNestedTextError(ErrCodeSchema,
+				fmt.Sprintf("conflicting keys flatten into key %q", e.key))
+		}
+		seen[e.key] = true
+	}
+	return flat, nil
+}
+
+// flattenEntries replaces entries holding non-empty dicts by the entries of these
+// dicts, flattened recursively, with the keys joined by the flatten separator.
+func (enc *Encoder) flattenEntries(entries []dictEntry) ([]dictEntry, error) {
+	var flat []dictEntry
+	for _, e := range entries {
+		nested, ok, err := enc.dictEntries(e.item)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || len(nested) == 0 {
+			flat = append(flat, e)
+			continue
+		}
+		if nested, err = enc.flattenEntries(nested); err != nil {
+			return nil, err
+		}
+		for _, n := range nested {
+			n.key = e.key + enc.flattenSeparator + n.key
+			n.field = n.field || e.field
+			flat = append(flat, n)
+		}
+	}
+	return flat, nil
+}
+
+// dictEntries returns the entries of item if it encodes as a dict.
+func (enc *Encoder) dictEntries(item interface{}) ([]dictEntry, bool, error) {
+	if c, ok := item.(converted); ok {
+		item = c.value
+	}
+	switch m := item.(type) {
+	case *OrderedMap:
+		if m == nil {
+			return nil, false, nil
+		}
+		entries, err := enc.orderedMapEntries(m)
+		return entries, true, err
+	case OrderedMap:
+		entries, err := enc.orderedMapEntries(&m)
+		return entries, true, err
+	}
+	v := reflect.ValueOf(item)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		entries, err := enc.mapEntries(v)
+		return entries, true, err
+	case reflect.Struct:
+		entries, err := enc.structEntries(v)
+		return entries, true, err
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,157 @@
+package nestedtext
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type flatConfig struct {
+	Name     string `nt:"name"`
+	Database struct {
+		Host string `nt:"host"`
+		Port int    `nt:"port"`
+		Pool struct {
+			Size int `nt:"size"`
+		} `nt:"pool"`
+	} `nt:"database"`
+	Tags map[string]string `nt:"tags"`
+}
+
+func TestExpandKeys(t *testing.T) {
+	input := `name: app
+database.host: db1
+database.port: 5432
+database:
+  pool.size: 10
+tags.env: prod
+`
+	var config flatConfig
+	if err := Unmarshal([]byte(input), &config, ExpandKeys("")); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if config.Database.Host != "db1" || config.Database.Port != 5432 || config.Database.Pool.Size != 10 ||
+		config.Tags["env"] != "prod" {
+		t.Errorf("got %+v", config)
+	}
+
+	result, err := Parse(strings.NewReader("a/b: 1\na/c:\n  - x\n..d: 2\n"), ExpandKeys("/"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := map[string]interface{}{
+		"a":   map[string]interface{}{"b": "1", "c": []interface{}{"x"}},
+		"..d": "2",
+	}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Parse() = %#v, want %#v", result, want)
+	}
+
+	// Without the option, dotted keys are plain keys
+	result, err = Parse(strings.NewReader("a.b: 1\n"))
+	if err != nil || !reflect.DeepEqual(result, map[string]interface{}{"a.b": "1"}) {
+		t.Errorf("Parse() without ExpandKeys = %#v, %v", result, err)
+	}
+}
+
+func TestExpandKeysConflicts(t *testing.T) {
+	tests := []struct {
+		input        string
+		line, column int
+		msg          string
+	}{
+		{"a: x\na.b: y\n", 2, 1, `key "a.b" conflicts with key "a" in line 1`},
+		{"a.b: y\na: x\n", 2, 1, `key "a" conflicts with key "a.b" in line 1`},
+		{"a.b: y\nx: 1\na:\n  b: z\n", 3, 1, `key "a" conflicts with key "a.b" in line 1`},
+		{"list:\n  -\n    a.b: 1\n    a.b.c: 2\n", 4, 5, `key "a.b.c" conflicts with key "a.b" in line 3`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(strings.NewReader(tt.input), ExpandKeys("."))
+		var nte NestedTextError
+		if !errors.As(err, &nte) {
+			t.Errorf("Parse(%q) = %v, want NestedTextError", tt.input, err)
+			continue
+		}
+		if nte.Line != tt.line || nte.Column != tt.column || !strings.Contains(err.Error(), tt.msg) {
+			t.Errorf("Parse(%q) = %v at %d:%d, want %q at %d:%d",
+				tt.input, err, nte.Line, nte.Column, tt.msg, tt.line, tt.column)
+		}
+	}
+}
+
+func TestEncodeFlattenKeys(t *testing.T) {
+	var config flatConfig
+	config.Name = "app"
+	config.Database.Host = "db1"
+	config.Database.Port = 5432
+	config.Database.Pool.Size = 10
+	config.Tags = map[string]string{"env": "prod", "team": "core"}
+
+	b, err := Marshal(config, WithFlattenKeys(".", 0))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `database.host: db1
+database.pool.size: 10
+database.port: 5432
+name: app
+tags.env: prod
+tags.team: core
+`
+	if string(b) != want {
+		t.Errorf("depth 0: Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	var back flatConfig
+	if err := Unmarshal(b, &back, ExpandKeys(".")); err != nil || !reflect.DeepEqual(back, config) {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+
+	b, err = Marshal(config, WithFlattenKeys(".", 1))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want = `database:
+  host: db1
+  pool.size: 10
+  port: 5432
+name: app
+tags:
+  env: prod
+  team: core
+`
+	if string(b) != want {
+		t.Errorf("depth 1: Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	if _, err := Marshal(config, WithFlattenKeys("", 0)); err == nil {
+		t.Error("expected error for empty separator")
+	}
+
+	// Empty dicts are kept as values
+	config.Tags = map[string]string{}
+	b, err = Marshal(config, WithFlattenKeys(".", 0))
+	if err != nil || !strings.Contains(string(b), "tags:\n  {}\n") {
+		t.Fatalf("Marshal() = %q, %v", b, err)
+	}
+	back = flatConfig{}
+	if err := Unmarshal(b, &back, ExpandKeys(".")); err != nil || !reflect.DeepEqual(back, config) {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+	nested := map[string]interface{}{"a": map[string]interface{}{"b": map[string]interface{}{}}, "c": "d"}
+	b, err = Marshal(nested, WithFlattenKeys(".", 0))
+	if err != nil || string(b) != "a.b:\n  {}\nc: d\n" {
+		t.Fatalf("Marshal() = %q, %v", b, err)
+	}
+	if got, err := Parse(strings.NewReader(string(b)), ExpandKeys(".")); err != nil || !reflect.DeepEqual(got, nested) {
+		t.Errorf("round trip = %#v, %v", got, err)
+	}
+
+	// Keys flattened into the same one are rejected
+	conflicting := map[string]interface{}{"a": map[string]interface{}{"b": "1"}, "a.b": "2"}
+	var nte NestedTextError
+	if _, err := Marshal(conflicting, WithFlattenKeys(".", 0)); !errors.As(err, &nte) || nte.Code != ErrCodeSchema {
+		t.Errorf("expected schema error for conflicting keys, got %v", err)
+	}
+}
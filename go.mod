@@ -1,3 +1,3 @@
 module github.com/danielledeleo/nestedtext
 
-go 1.16
+go 1.18
@@ -0,0 +1,227 @@
+package nestedtext
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// InferRule converts a NestedText string into a typed value, such as an int64. It
+// reports false if the string does not have the form the rule is looking for.
+type InferRule func(s string) (value interface{}, ok bool)
+
+// InferNull converts "null", "Null", "NULL" and "~" into nil.
+func InferNull(s string) (interface{}, bool) {
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil, true
+	}
+	return nil, false
+}
+
+// InferBool converts "true", "True", "TRUE", "false", "False" and "FALSE" into
+// a bool.
+func InferBool(s string) (interface{}, bool) {
+	switch s {
+	case "true", "True", "TRUE":
+		return true, true
+	case "false", "False", "FALSE":
+		return false, true
+	}
+	return nil, false
+}
+
+// InferInt converts decimal integers within the range of an int64, such as "42" or
+// "-7", into an int64.
+func InferInt(s string) (interface{}, bool) {
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, false
+	}
+	return i, true
+}
+
+// decimalFloatPattern matches decimal floating point numbers, leaving out the
+// special values and hexadecimal forms accepted by strconv.ParseFloat.
+var decimalFloatPattern = regexp.MustCompile(`^[-+]?([0-9]+(\.[0-9]*)?|\.[0-9]+)([eE][-+]?[0-9]+)?$`)
+
+// InferFloat converts decimal numbers, such as "3.14", "-0.5" or "1e-9", into a
+// float64.
+func InferFloat(s string) (interface{}, bool) {
+	if !decimalFloatPattern.MatchString(s) {
+		return nil, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, false
+	}
+	return f, true
+}
+
+// DefaultInferRules is the rule set used by InferTypes if no rules are given.
+var DefaultInferRules = []InferRule{InferNull, InferBool, InferInt, InferFloat}
+
+// InferTypes returns a DecodeOption that converts strings into typed values when
+// parsing with Parse or decoding into an interface{}. Each string is passed to the
+// rules in order, and the value of the first one that applies replaces it; strings
+// no rule applies to are kept. Without rules, DefaultInferRules is used, which
+// yields nil, bool, int64 and float64 values. See KeepStrings for exempting
+// individual values.
+func InferTypes(rules ...InferRule) DecodeOption {
+	return func(d *Decoder) error {
+		if len(rules) == 0 {
+			rules = DefaultInferRules
+		}
+		d.inferRules = rules
+		return nil
+	}
+}
+
+// KeepStrings returns a DecodeOption that exempts the values at the given key paths
+// from InferTypes, such as zip codes or version numbers. Paths are written like the
+// KeyPath of decoding errors, with dict keys separated by dots and list indices in
+// brackets, as in "servers[1].version". A "*" matches any key and "[*]" any index,
+// as in "servers[*].version". Paths are relative to the value being decoded. Values
+// within an exempted list or dict are exempted as well.
+func KeepStrings(paths ...string) DecodeOption {
+	return func(d *Decoder) error {
+		d.keepStrings = d.keepStrings[:0]
+		for _, path := range paths {
+			segments, err := splitKeyPath(path)
+			if err != nil {
+				return err
+			}
+			d.keepStrings = append(d.keepStrings, segments)
+		}
+		return nil
+	}
+}
+
+// splitKeyPath splits a key path into its segments: "a[1].b" into "a", "[1]" and "b".
+func splitKeyPath(path string) ([]string, error) {
+	var segments []string
+	for rest := path; rest != ""; {
+		var segment string
+		switch {
+		case rest[0] == '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, makeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid key path %q: missing ']'", path))
+			}
+			segment, rest = rest[:end+1], rest[end+1:]
+		default:
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			segment, rest = rest[:end], rest[end:]
+		}
+		if segment == "" || segment == "[]" {
+			return nil, makeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid key path %q: empty segment", path))
+		}
+		segments = append(segments, segment)
+		if strings.HasPrefix(rest, ".") {
+			if rest = rest[1:]; rest == "" {
+				return nil, makeNestedTextError(ErrCodeUsage, fmt.Sprintf("invalid key path %q: empty segment", path))
+			}
+		}
+	}
+	return segments, nil
+}
+
+// markKeptStrings records the nodes within n whose key paths match one of the
+// KeepStrings paths.
+func (d *Decoder) markKeptStrings(n *parse.Node) {
+	d.keptNodes = nil
+	if d.inferRules == nil || len(d.keepStrings) == 0 || n == nil {
+		return
+	}
+	d.keptNodes = make(map[*parse.Node]bool)
+	var walk func(n *parse.Node, path []string)
+	walk = func(n *parse.Node, path []string) {
+		for _, pattern := range d.keepStrings {
+			if matchKeyPath(pattern, path) {
+				d.keptNodes[n] = true
+				return
+			}
+		}
+		for i, item := range n.Items {
+			if n.Kind == parse.DictNode {
+				walk(item, append(path, n.Keys[i].Value))
+			} else {
+				walk(item, append(path, "["+strconv.Itoa(i)+"]"))
+			}
+		}
+	}
+	walk(n, nil)
+}
+
+// matchKeyPath reports whether path matches pattern segment by segment.
+func matchKeyPath(pattern, path []string) bool {
+	if len(pattern) != len(path) {
+		return false
+	}
+	for i, p := range pattern {
+		switch {
+		case p == path[i]:
+		case p == "*" && !strings.HasPrefix(path[i], "["):
+		case p == "[*]" && strings.HasPrefix(path[i], "["):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// interfaceValue converts n into string, []interface{} and map[string]interface{}
+// values, or *OrderedMap values for dicts if ordered is set. If infer is set, the
+// InferTypes rules are applied to strings not exempted by KeepStrings.
+func (d *Decoder) interfaceValue(n *parse.Node, infer, ordered bool) interface{} {
+	infer = infer && !d.keptNodes[n]
+	if !infer && !ordered {
+		return n.Interface()
+	}
+	switch n.Kind {
+	case parse.ListNode:
+		list := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			list[i] = d.interfaceValue(item, infer, ordered)
+		}
+		return list
+	case parse.DictNode:
+		if ordered {
+			dict := &OrderedMap{}
+			for i, item := range n.Items {
+				dict.Set(n.Keys[i].Value, d.interfaceValue(item, infer, ordered))
+			}
+			return dict
+		}
+		dict := make(map[string]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			dict[n.Keys[i].Value] = d.interfaceValue(item, infer, ordered)
+		}
+		return dict
+	}
+	if infer {
+		for _, rule := range d.inferRules {
+			if value, ok := rule(n.Value); ok {
+				return value
+			}
+		}
+	}
+	return n.Value
+}
+
+// setInterface stores the value of n in interface v.
+func (d *Decoder) setInterface(n *parse.Node, v reflect.Value) {
+	value := d.interfaceValue(n, d.inferRules != nil, d.orderedDicts)
+	if value == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return
+	}
+	v.Set(reflect.ValueOf(value))
+}
@@ -0,0 +1,126 @@
+package nestedtext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestInferTypes(t *testing.T) {
+	input := `name: server
+port: 8080
+ratio: 0.75
+debug: true
+proxy: null
+version: 1.10
+zip: 02134
+hosts:
+  -
+    host: a
+    weight: 1e3
+  -
+    host: b
+    weight: inf
+`
+	want := map[string]interface{}{
+		"name":    "server",
+		"port":    int64(8080),
+		"ratio":   0.75,
+		"debug":   true,
+		"proxy":   nil,
+		"version": "1.10",
+		"zip":     "02134",
+		"hosts": []interface{}{
+			map[string]interface{}{"host": "a", "weight": 1000.0},
+			map[string]interface{}{"host": "b", "weight": "inf"},
+		},
+	}
+
+	got, err := Parse(strings.NewReader(input), InferTypes(), KeepStrings("version", "zip"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v\nwant %#v", got, want)
+	}
+
+	var v interface{}
+	if err := Unmarshal([]byte(input), &v, InferTypes(), KeepStrings("version", "zip")); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("Unmarshal() = %#v\nwant %#v", v, want)
+	}
+
+	// Without the option, everything stays a string
+	got, err = Parse(strings.NewReader("port: 8080\n"))
+	if err != nil || !reflect.DeepEqual(got, map[string]interface{}{"port": "8080"}) {
+		t.Errorf("Parse() without InferTypes = %#v, %v", got, err)
+	}
+}
+
+func TestInferTypesIntoFields(t *testing.T) {
+	var config struct {
+		Port  int
+		Extra map[string]interface{}
+		Tags  []interface{}
+	}
+	input := "port: 80\nextra:\n  retries: 3\n  release: 2.0\nTags:\n  - 1\n  - ~\n"
+	if err := Unmarshal([]byte(input), &config, InferTypes(), KeepStrings("extra.release", "Tags[0]")); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if config.Port != 80 {
+		t.Errorf("Port = %d, want 80", config.Port)
+	}
+	wantExtra := map[string]interface{}{"retries": int64(3), "release": "2.0"}
+	if !reflect.DeepEqual(config.Extra, wantExtra) {
+		t.Errorf("Extra = %#v, want %#v", config.Extra, wantExtra)
+	}
+	if wantTags := []interface{}{"1", nil}; !reflect.DeepEqual(config.Tags, wantTags) {
+		t.Errorf("Tags = %#v, want %#v", config.Tags, wantTags)
+	}
+}
+
+func TestInferTypesCustomRules(t *testing.T) {
+	yesNo := func(s string) (interface{}, bool) {
+		switch s {
+		case "yes":
+			return true, true
+		case "no":
+			return false, true
+		}
+		return nil, false
+	}
+	got, err := Parse(strings.NewReader("- yes\n- 12\n- no\n-\n  > a\n  > b\n"), InferTypes(yesNo, InferInt), KeepStrings("[*]"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if want := []interface{}{"yes", "12", "no", "a\nb"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("with KeepStrings([*]): Parse() = %#v, want %#v", got, want)
+	}
+
+	got, err = Parse(strings.NewReader("a:\n  - yes\n  - 12\nb: no\n"), InferTypes(yesNo, InferInt), KeepStrings("*[1]"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := map[string]interface{}{"a": []interface{}{true, "12"}, "b": false}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+
+	// Empty keys match "*"
+	got, err = Parse(strings.NewReader("{: 5, a: 6}\n"), InferTypes(), KeepStrings("*"))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want = map[string]interface{}{"": "5", "a": "6"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %#v, want %#v", got, want)
+	}
+
+	for _, path := range []string{"a..b", "a[1", "a.", "[]"} {
+		if _, err := Parse(strings.NewReader("a: b"), KeepStrings(path)); err == nil {
+			t.Errorf("KeepStrings(%q): expected error", path)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package parse
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 // InlineItemParser parses inline lists and dicts.
@@ -25,6 +26,7 @@ type InlineItemParser struct {
 	Marker       int             // positional marker for start of key or value
 	Input        *strings.Reader // reader for Text
 	LineNo       int             // current input line number
+	ColNo        int             // column of the first character of Text
 	Stack        Stack           // parser stack
 
 	// Error creation functions
@@ -43,7 +45,19 @@ func NewInlineParser(wrapIOError func(string, error) error, makeFormatError func
 	}
 }
 
-func (p *InlineItemParser) Parse(initial InlineParserState, input string, makeFormatError func(string) error) (result interface{}, err error) {
+// Parse parses an inline item and returns it as string, []interface{} or
+// map[string]interface{}.
+func (p *InlineItemParser) Parse(initial InlineParserState, input string, makeFormatError func(string) error) (interface{}, error) {
+	node, err := p.ParseNode(initial, input, makeFormatError)
+	if err != nil {
+		return nil, err
+	}
+	return node.Interface(), nil
+}
+
+// ParseNode parses an inline item and returns it as a node hierarchy, with
+// positions relative to LineNo and ColNo.
+func (p *InlineItemParser) ParseNode(initial InlineParserState, input string, makeFormatError func(string) error) (result *Node, err error) {
 	p.Text = input
 	p.Input = strings.NewReader(input)
 	p.Stack = p.Stack[:0]
@@ -73,7 +87,7 @@ func (p *InlineItemParser) Parse(initial InlineParserState, input string, makeFo
 			break
 		}
 		if IsAccept(state) {
-			result, err = p.Stack.Tos().ReduceToItem()
+			result, err = p.Stack.Tos().ReduceToNode()
 			if err != nil {
 				p.Stack.Tos().Error = err
 				state = StateError
@@ -113,16 +127,30 @@ func (p *InlineItemParser) Parse(initial InlineParserState, input string, makeFo
 // the non-terminal represents a list item or a dict item, the .Keys slice will be initialized.
 func (p *InlineItemParser) pushNonterm(state InlineParserState) {
 	entry := StackEntry{
-		Values: make([]interface{}, 0, 16),
+		Values: make([]*Node, 0, 16),
+		LineNo: p.LineNo,
+		ColNo:  p.column(p.TextPosition),
 	}
 	if state == StateS1 { // dict
-		entry.Keys = make([]string, 0, 16)
+		entry.Keys = make([]*Node, 0, 16)
 	}
 	p.Stack.Push(&entry)
 }
 
+// column returns the input column of a byte offset into Text.
+func (p *InlineItemParser) column(offset int) int {
+	return p.ColNo + utf8.RuneCountInString(p.Text[:offset])
+}
+
+// trimmedNode creates a string node from Text[from:to] with surrounding whitespace
+// removed. The node is located at the first non-whitespace character.
+func (p *InlineItemParser) trimmedNode(from, to int) *Node {
+	raw := p.Text[from:to]
+	start := from + len(raw) - len(strings.TrimLeftFunc(raw, unicode.IsSpace))
+	return NewStringNode(strings.TrimSpace(raw), p.LineNo, p.column(start))
+}
+
 func (p *InlineItemParser) appendStringValue(isAccept bool, makeFormatError func(string) error) error {
-	value := p.Text[p.Marker:p.TextPosition]
 	// From the spec:
 	// Both inline lists and dictionaries may be empty, and represent the only way to
 	// represent empty lists or empty dictionaries in NestedText. An empty dictionary
@@ -135,10 +163,10 @@ func (p *InlineItemParser) appendStringValue(isAccept bool, makeFormatError func
 	// Thus, [] represents an empty list, [ ] a list with a single empty string value,
 	// and [,] a list with two empty string values.
 	if p.Stack.Tos().Key != nil {
-		value = strings.TrimSpace(value)
+		value := p.trimmedNode(p.Marker, p.TextPosition)
 		return p.Stack.PushKV(p.Stack.Tos().Key, value, makeFormatError)
-	} else if !isAccept || len(value) > 0 || len(p.Stack.Tos().Values) > 0 {
-		value = strings.TrimSpace(value)
+	} else if !isAccept || p.TextPosition > p.Marker || len(p.Stack.Tos().Values) > 0 {
+		value := p.trimmedNode(p.Marker, p.TextPosition)
 		return p.Stack.PushKV(p.Stack.Tos().Key, value, makeFormatError)
 	}
 	return nil
@@ -213,9 +241,7 @@ var inlineStateMachineActions = [...]inlineAction{
 	nop, // 2
 	func(p *InlineItemParser, from, to InlineParserState, ch rune, w int, makeFormatError func(string) error) bool { // 3
 		if from != 3 {
-			key := p.Text[p.Marker:p.TextPosition]
-			key = strings.TrimSpace(key)
-			p.Stack.Tos().Key = &key
+			p.Stack.Tos().Key = p.trimmedNode(p.Marker, p.TextPosition)
 			p.Marker = p.TextPosition + w // get ready for value
 		}
 		return true
@@ -0,0 +1,51 @@
+package parse
+
+// NodeKind is the kind of a NestedText item.
+type NodeKind int8
+
+const (
+	StringNode NodeKind = iota // string item
+	ListNode                   // list item
+	DictNode                   // dict item
+)
+
+// Node is a NestedText item together with its position in the input source.
+// Strings carry their content in Value. Lists and dicts carry their children in
+// Items, in document order; dicts additionally carry their keys as string nodes
+// in Keys, parallel to Items.
+type Node struct {
+	Kind          NodeKind
+	Value         string  // content of a string item
+	Items         []*Node // list items or dict values
+	Keys          []*Node // dict keys, nil for strings and lists
+	LineNo, ColNo int     // start of the item within the input source
+}
+
+// NewStringNode creates a string node located at the given position.
+func NewStringNode(s string, line, col int) *Node {
+	return &Node{Kind: StringNode, Value: s, LineNo: line, ColNo: col}
+}
+
+// Interface converts a node hierarchy into the plain representation used by the
+// top-level API: string, []interface{} or map[string]interface{}.
+// A nil node converts to nil.
+func (n *Node) Interface() interface{} {
+	if n == nil {
+		return nil
+	}
+	switch n.Kind {
+	case ListNode:
+		list := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			list[i] = item.Interface()
+		}
+		return list
+	case DictNode:
+		dict := make(map[string]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			dict[n.Keys[i].Value] = item.Interface()
+		}
+		return dict
+	}
+	return n.Value
+}
@@ -41,18 +41,24 @@ func NewParser(makeFormatError func(string) error, wrapIOError func(string, erro
 
 // Parse parses the input from r and returns the result.
 func (p *Parser) Parse(r io.Reader, makeFormatError func(string) error, wrapIOError func(string, error) error, errCodeNoInput int) (result interface{}, err error) {
+	node, err := p.ParseNode(r, makeFormatError, wrapIOError, errCodeNoInput)
+	if err != nil {
+		return nil, err
+	}
+	return p.WrapResult(node.Interface()), nil
+}
+
+// ParseNode parses the input from r and returns the resulting node hierarchy.
+// An empty document results in a nil node.
+func (p *Parser) ParseNode(r io.Reader, makeFormatError func(string) error, wrapIOError func(string, error) error, errCodeNoInput int) (result *Node, err error) {
 	p.Sc, err = NewScanner(r, makeFormatError, wrapIOError, p.MakeParsingError, p.ErrCodeFormat, errCodeNoInput)
 	if err != nil {
 		return
 	}
-	result, err = p.parseDocument()
-	if err == nil {
-		result = p.WrapResult(result)
-	}
-	return
+	return p.parseDocument()
 }
 
-func (p *Parser) parseDocument() (result interface{}, err error) {
+func (p *Parser) parseDocument() (result *Node, err error) {
 	// initial token from scanner is a health check for the input source
 	if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 		return nil, p.Token.Error
@@ -72,7 +78,7 @@ func (p *Parser) parseDocument() (result interface{}, err error) {
 	return
 }
 
-func (p *Parser) parseAny(indent int) (result interface{}, err error) {
+func (p *Parser) parseAny(indent int) (result *Node, err error) {
 	if p.Token.Indent < indent {
 		return nil, nil
 	}
@@ -90,11 +96,12 @@ func (p *Parser) parseAny(indent int) (result interface{}, err error) {
 				"inline list syntax is not allowed in minimal mode")
 		}
 		p.Inline.LineNo = p.Token.LineNo
+		p.Inline.ColNo = p.Token.ValueColNo
 		inlineToken := p.Token
 		makeErr := func(msg string) error {
 			return p.MakeParsingError(inlineToken, p.ErrCodeFormat, msg)
 		}
-		result, err = p.Inline.Parse(StateS2, p.Token.Content[0], makeErr)
+		result, err = p.Inline.ParseNode(StateS2, p.Token.Content[0], makeErr)
 		if err == nil {
 			if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 				return nil, p.Token.Error
@@ -106,11 +113,12 @@ func (p *Parser) parseAny(indent int) (result interface{}, err error) {
 				"inline dict syntax is not allowed in minimal mode")
 		}
 		p.Inline.LineNo = p.Token.LineNo
+		p.Inline.ColNo = p.Token.ValueColNo
 		inlineToken := p.Token
 		makeErr := func(msg string) error {
 			return p.MakeParsingError(inlineToken, p.ErrCodeFormat, msg)
 		}
-		result, err = p.Inline.Parse(StateS1, p.Token.Content[0], makeErr)
+		result, err = p.Inline.ParseNode(StateS1, p.Token.Content[0], makeErr)
 		if err == nil {
 			if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 				return nil, p.Token.Error
@@ -130,19 +138,19 @@ func (p *Parser) parseAny(indent int) (result interface{}, err error) {
 	return
 }
 
-func (p *Parser) parseList(indent int) (result interface{}, err error) {
+func (p *Parser) parseList(indent int) (result *Node, err error) {
 	p.pushNonterm(false)
 	_, err = p.parseListItems(p.Token.Indent)
 	if err != nil {
 		return nil, err
 	}
-	result, err = p.Stack.Tos().ReduceToItem()
+	result, err = p.Stack.Tos().ReduceToNode()
 	p.Stack.Pop()
 	return
 }
 
-func (p *Parser) parseListItems(indent int) (result interface{}, err error) {
-	var value interface{}
+func (p *Parser) parseListItems(indent int) (result []*Node, err error) {
+	var value *Node
 	for p.Token.TokenType == ListItem || p.Token.TokenType == ListItemMultiline {
 		if p.Token.TokenType == ListItem {
 			value, err = p.parseListItem(indent)
@@ -166,7 +174,7 @@ func (p *Parser) parseListItems(indent int) (result interface{}, err error) {
 	return p.Stack.Tos().Values, err
 }
 
-func (p *Parser) parseListItem(indent int) (result interface{}, err error) {
+func (p *Parser) parseListItem(indent int) (result *Node, err error) {
 	if p.Token.Indent > indent {
 		return nil, p.MakeParsingError(p.Token, p.ErrCodeFormat,
 			"invalid indent: may only follow an item that does not already have a value")
@@ -174,22 +182,23 @@ func (p *Parser) parseListItem(indent int) (result interface{}, err error) {
 	if p.Token.Indent < indent {
 		return nil, nil
 	}
-	value := p.Token.Content[0]
+	value := NewStringNode(p.Token.Content[0], p.Token.LineNo, p.Token.ValueColNo)
 	if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 		return nil, p.Token.Error
 	}
 	return value, err
 }
 
-func (p *Parser) parseListItemMultiline(indent int) (result interface{}, err error) {
+func (p *Parser) parseListItemMultiline(indent int) (result *Node, err error) {
 	if p.Token.Indent != indent {
 		return nil, nil
 	}
+	itemToken := p.Token
 	if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 		return nil, p.Token.Error
 	}
 	if p.Token.Indent <= indent {
-		return "", nil
+		return NewStringNode("", itemToken.LineNo, itemToken.Indent+2), nil
 	}
 	result, err = p.parseAny(p.Token.Indent)
 	if p.Token.Indent > indent {
@@ -199,13 +208,13 @@ func (p *Parser) parseListItemMultiline(indent int) (result interface{}, err err
 	return
 }
 
-func (p *Parser) parseDict(indent int) (result interface{}, err error) {
+func (p *Parser) parseDict(indent int) (result *Node, err error) {
 	p.pushNonterm(true)
 	_, err = p.parseDictKeyValuePairs(p.Token.Indent)
 	if err != nil {
 		return nil, err
 	}
-	result, err = p.Stack.Tos().ReduceToItem()
+	result, err = p.Stack.Tos().ReduceToNode()
 	p.Stack.Pop()
 	if p.Token.Indent > indent {
 		err = p.MakeParsingError(p.Token, p.ErrCodeFormat, "partial dedent")
@@ -215,11 +224,11 @@ func (p *Parser) parseDict(indent int) (result interface{}, err error) {
 
 // keyValuePair is a helper type to hold dict key-values as return-type.
 type keyValuePair struct {
-	key   *string
-	value interface{}
+	key   *Node
+	value *Node
 }
 
-func (p *Parser) parseDictKeyValuePairs(indent int) (result interface{}, err error) {
+func (p *Parser) parseDictKeyValuePairs(indent int) (result []*Node, err error) {
 	var kv keyValuePair
 	for p.Token.TokenType == InlineDictKeyValue || p.Token.TokenType == InlineDictKey ||
 		p.Token.TokenType == DictKeyMultiline {
@@ -258,24 +267,25 @@ func (p *Parser) parseDictKeyValuePair(indent int) (kv keyValuePair, err error)
 	if p.Token.Indent != indent {
 		return
 	}
-	key := p.Token.Content[0]
-	value := p.Token.Content[1]
+	key := NewStringNode(p.Token.Content[0], p.Token.LineNo, p.Token.Indent+1)
+	value := NewStringNode(p.Token.Content[1], p.Token.LineNo, p.Token.ValueColNo)
 	if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 		return kv, p.Token.Error
 	}
-	return keyValuePair{key: &key, value: value}, err
+	return keyValuePair{key: key, value: value}, err
 }
 
 func (p *Parser) parseDictKeyAnyValuePair(indent int) (kv keyValuePair, err error) {
 	if p.Token.Indent != indent {
 		return
 	}
-	kv.key = &p.Token.Content[0]
+	keyToken := p.Token
+	kv.key = NewStringNode(p.Token.Content[0], p.Token.LineNo, p.Token.Indent+1)
 	if p.Token = p.Sc.NextToken(); p.Token.Error != nil {
 		return kv, p.Token.Error
 	}
 	if p.Token.Indent <= indent {
-		kv.value = ""
+		kv.value = NewStringNode("", keyToken.LineNo, keyToken.ValueColNo)
 		return
 	}
 	kv.value, err = p.parseAny(p.Token.Indent)
@@ -293,6 +303,7 @@ func (p *Parser) parseDictKeyValuePairWithMultilineKey(indent int) (kv keyValueP
 	if p.Token.Indent != indent {
 		return
 	}
+	keyToken := p.Token
 	builder := strings.Builder{}
 	builder.WriteString(allowVoid(p.Token.Content, 0))
 	for err == nil {
@@ -306,8 +317,7 @@ func (p *Parser) parseDictKeyValuePairWithMultilineKey(indent int) (kv keyValueP
 		builder.WriteRune('\n')
 		builder.WriteString(allowVoid(p.Token.Content, 0))
 	}
-	key := builder.String()
-	kv.key = &key
+	kv.key = NewStringNode(builder.String(), keyToken.LineNo, keyToken.Indent+1)
 	// Multiline key MUST be followed by an indented value
 	if p.Token.Indent <= indent {
 		return kv, p.MakeParsingError(p.Token, p.ErrCodeFormat, "multiline key requires a value")
@@ -316,16 +326,18 @@ func (p *Parser) parseDictKeyValuePairWithMultilineKey(indent int) (kv keyValueP
 	return
 }
 
-func (p *Parser) parseMultiString(indent int) (result interface{}, err error) {
+func (p *Parser) parseMultiString(indent int) (result *Node, err error) {
 	if p.Token.Indent != indent {
 		return nil, nil
 	}
+	result = NewStringNode("", p.Token.LineNo, p.Token.Indent+1)
 	builder := strings.Builder{}
 	builder.WriteString(allowVoid(p.Token.Content, 0))
 	for err == nil {
 		p.Token = p.Sc.NextToken()
 		if p.Token.Error != nil {
-			return builder.String(), p.Token.Error
+			result.Value = builder.String()
+			return result, p.Token.Error
 		}
 		if p.Token.TokenType != StringMultiline || p.Token.Indent != indent {
 			break
@@ -333,15 +345,18 @@ func (p *Parser) parseMultiString(indent int) (result interface{}, err error) {
 		builder.WriteRune('\n')
 		builder.WriteString(allowVoid(p.Token.Content, 0))
 	}
-	return builder.String(), nil
+	result.Value = builder.String()
+	return result, nil
 }
 
 func (p *Parser) pushNonterm(isDict bool) {
 	entry := StackEntry{
-		Values: make([]interface{}, 0, 16),
+		Values: make([]*Node, 0, 16),
+		LineNo: p.Token.LineNo,
+		ColNo:  p.Token.Indent + 1,
 	}
 	if isDict { // dict
-		entry.Keys = make([]string, 0, 16)
+		entry.Keys = make([]*Node, 0, 16)
 	}
 	p.Stack.Push(&entry)
 }
@@ -0,0 +1,53 @@
+package parse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNodePositions(t *testing.T) {
+	input := `name: myapp
+hosts:
+  - localhost
+tags:
+  [a, b]
+text:
+  > line one
+  > line two
+`
+	p := NewParser(testFormatError, testIOError, testParsingError, testErrCodeFormat)
+	root, err := p.ParseNode(strings.NewReader(input), testFormatError, testIOError, testErrCodeFormat+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hosts := root.Items[1]
+	inline := root.Items[2]
+	inputs := []struct {
+		what      string
+		node      *Node
+		line, col int
+		kind      NodeKind
+		value     string
+	}{
+		{"root", root, 1, 1, DictNode, ""},
+		{"key name", root.Keys[0], 1, 1, StringNode, "name"},
+		{"value name", root.Items[0], 1, 7, StringNode, "myapp"},
+		{"key hosts", root.Keys[1], 2, 1, StringNode, "hosts"},
+		{"list hosts", hosts, 3, 3, ListNode, ""},
+		{"item localhost", hosts.Items[0], 3, 5, StringNode, "localhost"},
+		{"inline list", inline, 5, 3, ListNode, ""},
+		{"inline item b", inline.Items[1], 5, 7, StringNode, "b"},
+		{"multi-line string", root.Items[3], 7, 3, StringNode, "line one\nline two"},
+	}
+	for _, input := range inputs {
+		n := input.node
+		if n.Kind != input.kind || n.Value != input.value {
+			t.Errorf("%s: got kind %d value %q, want kind %d value %q", input.what,
+				n.Kind, n.Value, input.kind, input.value)
+		}
+		if n.LineNo != input.line || n.ColNo != input.col {
+			t.Errorf("%s: got position [%d,%d], want [%d,%d]", input.what,
+				n.LineNo, n.ColNo, input.line, input.col)
+		}
+	}
+}
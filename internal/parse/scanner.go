@@ -232,6 +232,7 @@ func (sc *Scanner) recognizeItemTag(tag rune, single, multi TokenType, token *To
 	if sc.Buf.Lookahead == ' ' {
 		sc.Buf.Match(SingleRune(' '))
 		token.TokenType = single
+		token.ValueColNo = int(sc.Buf.Cursor)
 		token.Content = append(token.Content, sc.Buf.ReadLineRemainder())
 		return token
 	}
@@ -249,6 +250,7 @@ func (sc *Scanner) recognizeInlineItem(toktype TokenType, token *Token) *Token {
 				sc.Buf.Lookahead, rune(closing)))
 	}
 	token.TokenType = toktype
+	token.ValueColNo = int(sc.Buf.Cursor)
 	token.Content = append(token.Content, sc.Buf.ReadLineRemainder())
 	return token
 }
@@ -32,22 +32,22 @@ func (s *Stack) Push(e *StackEntry) (tos *StackEntry) {
 // top-most stack entry.
 // The containing stack-entry has to be provided by a non-term (pushNonterm).
 // Returns an error if a duplicate key is detected.
-func (s *Stack) PushKV(str *string, val interface{}, makeFormatError func(string) error) error {
+func (s *Stack) PushKV(key *Node, val *Node, makeFormatError func(string) error) error {
 	if s == nil || len(*s) == 0 {
 		panic("use of un-initialized parser stack")
 	}
 	tos := &(*s)[len(*s)-1]
-	if str != nil {
+	if key != nil {
 		if tos.Keys == nil {
 			return makeFormatError("unexpected key in non-dict context")
 		}
 		// Check for duplicate key
 		for _, k := range tos.Keys {
-			if k == *str {
-				return makeFormatError(fmt.Sprintf("duplicate key: %s", *str))
+			if k.Value == key.Value {
+				return makeFormatError(fmt.Sprintf("duplicate key: %s", key.Value))
 			}
 		}
-		tos.Keys = append(tos.Keys, *str)
+		tos.Keys = append(tos.Keys, key)
 	}
 	tos.Values = append(tos.Values, val)
 	return nil
@@ -56,26 +56,32 @@ func (s *Stack) PushKV(str *string, val interface{}, makeFormatError func(string
 // StackEntry represents the parser stack entry for a non-terminal.
 // Stack entries collect the information for an item, either a list or a dict.
 type StackEntry struct {
-	Values       []interface{}      // list of values, either list items or dict values
-	Keys         []string           // list of keys, empty for list items
-	Key          *string            // current key to set value for, if in a dict
-	Error        error              // if error occurred: remember it
-	NontermState InlineParserState  // sub-nonterm, or 0 for root entry (used for inline-parser only)
+	Values        []*Node           // list of values, either list items or dict values
+	Keys          []*Node           // list of keys, empty for list items
+	Key           *Node             // current key to set value for, if in a dict
+	LineNo, ColNo int               // start of the item within the input source
+	Error         error             // if error occurred: remember it
+	NontermState  InlineParserState // sub-nonterm, or 0 for root entry (used for inline-parser only)
 }
 
-func (entry StackEntry) ReduceToItem() (interface{}, error) {
+// ReduceToNode converts a stack entry into a list node or a dict node.
+func (entry StackEntry) ReduceToNode() (*Node, error) {
+	node := &Node{
+		Kind:   ListNode,
+		Items:  entry.Values,
+		LineNo: entry.LineNo,
+		ColNo:  entry.ColNo,
+	}
 	if entry.Keys == nil {
-		return entry.Values, nil
+		return node, nil
 	}
-	dict := make(map[string]interface{}, len(entry.Values))
 	if len(entry.Keys) > 0 && len(entry.Values) != len(entry.Keys) {
 		panic(fmt.Sprintf("mixed item: number of keys (%d) not equal to number of values (%d)",
 			len(entry.Keys), len(entry.Values)))
 	}
-	for i, key := range entry.Keys {
-		dict[key] = entry.Values[i]
-	}
-	return dict, nil
+	node.Kind = DictNode
+	node.Keys = entry.Keys
+	return node, nil
 }
 
 // InlineParserState represents states in the inline parser automaton
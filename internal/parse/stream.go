@@ -0,0 +1,323 @@
+package parse
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// EventKind is the kind of an event produced by a Streamer.
+type EventKind int8
+
+const (
+	EventEOF       EventKind = iota // end of input
+	EventDictStart                  // start of a dict
+	EventDictEnd                    // end of a dict
+	EventListStart                  // start of a list
+	EventListEnd                    // end of a list
+	EventKey                        // dict key
+	EventString                     // string item
+)
+
+// Event is an element of the stream produced by a Streamer. Keys and strings carry
+// their content in Value. End events carry the position of the item they close.
+type Event struct {
+	Kind          EventKind
+	Value         string
+	LineNo, ColNo int
+}
+
+// Streamer produces the items of a NestedText input source as a stream of events in
+// document order, consuming the input line by line. Unlike Parser, it does not build
+// up the item hierarchy, so it needs memory proportional to the nesting depth and the
+// number of keys of the dicts currently open, rather than to the size of the input.
+// Inline lists and dicts are parsed as a whole, as they span a single line.
+type Streamer struct {
+	Sc          *Scanner          // line level scanner
+	Inline      *InlineItemParser // sub-parser for inline lists/dicts
+	MinimalMode bool              // if true, reject inline syntax and multi-line keys
+
+	token   *Token       // the current token from the scanner
+	started bool         // the first item line has been read
+	done    bool         // the top-level item is complete
+	open    []openItem   // lists and dicts not yet closed, innermost last
+	expect  *expectation // value expected after a key or list tag, if any
+	pending []Event      // events not yet delivered
+	err     error        // error condition, delivered after the pending events
+
+	// Error creation functions
+	MakeParsingError func(token *Token, code int, msg string) error
+	ErrCodeFormat    int
+}
+
+// openItem is a list or dict whose end has not been reached yet.
+type openItem struct {
+	dict          bool
+	indent        int
+	lineNo, colNo int
+	keys          map[string]bool
+}
+
+// expectation describes a value expected to follow on more indented lines.
+type expectation struct {
+	indent        int  // indent of the key or list tag
+	lineNo, colNo int  // position of the value if it turns out to be empty
+	required      bool // an empty value is an error
+}
+
+// NewStreamer creates a streamer for an input reader.
+func NewStreamer(r io.Reader, makeFormatError func(string) error, wrapIOError func(string, error) error, makeParsingError func(*Token, int, string) error, errCodeFormat int, errCodeNoInput int) (*Streamer, error) {
+	sc, err := NewScanner(r, makeFormatError, wrapIOError, makeParsingError, errCodeFormat, errCodeNoInput)
+	if err != nil {
+		return nil, err
+	}
+	return &Streamer{
+		Sc:               sc,
+		Inline:           NewInlineParser(wrapIOError, makeParsingError, errCodeFormat),
+		MakeParsingError: makeParsingError,
+		ErrCodeFormat:    errCodeFormat,
+	}, nil
+}
+
+// Next returns the next event and removes it from the stream. At the end of the
+// input, it returns an EventEOF event.
+func (s *Streamer) Next() (Event, error) {
+	event, err := s.Peek()
+	if err == nil && event.Kind != EventEOF {
+		s.pending = s.pending[1:]
+	}
+	return event, err
+}
+
+// Peek returns the next event without removing it from the stream.
+func (s *Streamer) Peek() (Event, error) {
+	for len(s.pending) == 0 {
+		if s.err != nil {
+			return Event{}, s.err
+		}
+		if s.done && s.token.TokenType == EOF {
+			return Event{Kind: EventEOF, LineNo: s.token.LineNo, ColNo: s.token.ColNo}, nil
+		}
+		s.err = s.step()
+	}
+	return s.pending[0], nil
+}
+
+// step consumes input until at least one event is produced or an error occurs.
+func (s *Streamer) step() error {
+	if !s.started {
+		s.started = true
+		// initial token from scanner is a health check for the input source
+		if s.token = s.Sc.NextToken(); s.token.Error != nil {
+			return s.token.Error
+		}
+		if s.token.TokenType == EOF || s.token.TokenType == EmptyDocument {
+			s.token.TokenType = EOF
+			s.done = true
+			return nil
+		}
+		return s.advance()
+	}
+
+	t := s.token
+	if e := s.expect; e != nil {
+		s.expect = nil
+		if t.TokenType == EOF || t.Indent <= e.indent {
+			if e.required {
+				return s.MakeParsingError(t, s.ErrCodeFormat, "multiline key requires a value")
+			}
+			s.emit(EventString, "", e.lineNo, e.colNo)
+			return nil
+		}
+		return s.startItem()
+	}
+	if len(s.open) > 0 {
+		top := &s.open[len(s.open)-1]
+		if t.TokenType == EOF || t.Indent < top.indent {
+			s.close()
+			if len(s.open) > 0 && t.TokenType != EOF && t.Indent > s.open[len(s.open)-1].indent {
+				return s.MakeParsingError(t, s.ErrCodeFormat, "partial dedent")
+			}
+			return nil
+		}
+		if t.Indent > top.indent {
+			return s.MakeParsingError(t, s.ErrCodeFormat,
+				"invalid indent: may only follow an item that does not already have a value")
+		}
+		return s.continueItem(top)
+	}
+	if s.done {
+		return s.MakeParsingError(t, s.ErrCodeFormat, "unused content following valid input")
+	}
+	return s.startItem()
+}
+
+// startItem produces the events for the start of an item at the current token.
+func (s *Streamer) startItem() error {
+	t := s.token
+	if len(s.open) >= maxNestingDepth {
+		return s.MakeParsingError(t, s.ErrCodeFormat, "exceeded max nesting depth")
+	}
+	switch t.TokenType {
+	case StringMultiline:
+		lineNo, colNo, indent := t.LineNo, t.Indent+1, t.Indent
+		builder := strings.Builder{}
+		builder.WriteString(allowVoid(t.Content, 0))
+		for {
+			if err := s.advance(); err != nil {
+				return err
+			}
+			if s.token.TokenType != StringMultiline || s.token.Indent != indent {
+				break
+			}
+			builder.WriteRune('\n')
+			builder.WriteString(allowVoid(s.token.Content, 0))
+		}
+		s.emit(EventString, builder.String(), lineNo, colNo)
+		s.itemDone()
+		return nil
+	case InlineList, InlineDict:
+		initial, kind := StateS2, "list"
+		if t.TokenType == InlineDict {
+			initial, kind = StateS1, "dict"
+		}
+		if s.MinimalMode {
+			return s.MakeParsingError(t, s.ErrCodeFormat,
+				fmt.Sprintf("inline %s syntax is not allowed in minimal mode", kind))
+		}
+		s.Inline.LineNo = t.LineNo
+		s.Inline.ColNo = t.ValueColNo
+		makeErr := func(msg string) error {
+			return s.MakeParsingError(t, s.ErrCodeFormat, msg)
+		}
+		node, err := s.Inline.ParseNode(initial, t.Content[0], makeErr)
+		if err != nil {
+			return err
+		}
+		s.emitNode(node)
+		s.itemDone()
+		return s.advance()
+	case ListItem, ListItemMultiline:
+		s.open = append(s.open, openItem{indent: t.Indent, lineNo: t.LineNo, colNo: t.Indent + 1})
+		s.emit(EventListStart, "", t.LineNo, t.Indent+1)
+	case InlineDictKeyValue, InlineDictKey, DictKeyMultiline:
+		s.open = append(s.open, openItem{dict: true, indent: t.Indent, lineNo: t.LineNo, colNo: t.Indent + 1,
+			keys: make(map[string]bool)})
+		s.emit(EventDictStart, "", t.LineNo, t.Indent+1)
+	default:
+		return s.MakeParsingError(t, s.ErrCodeFormat, fmt.Sprintf("internal error: unknown item type %d", t.TokenType))
+	}
+	return s.continueItem(&s.open[len(s.open)-1])
+}
+
+// continueItem produces the events for the next entry of list or dict top, which
+// starts at the current token.
+func (s *Streamer) continueItem(top *openItem) error {
+	t := s.token
+	if !top.dict {
+		switch t.TokenType {
+		case ListItem:
+			s.emit(EventString, t.Content[0], t.LineNo, t.ValueColNo)
+		case ListItemMultiline:
+			s.expect = &expectation{indent: t.Indent, lineNo: t.LineNo, colNo: t.Indent + 2}
+		default:
+			return s.MakeParsingError(t, s.ErrCodeFormat, "list item expected")
+		}
+		return s.advance()
+	}
+
+	// Read the key, which may span several lines, before producing any events
+	key := allowVoid(t.Content, 0)
+	var value *Event
+	switch t.TokenType {
+	case InlineDictKeyValue:
+		value = &Event{Kind: EventString, Value: t.Content[1], LineNo: t.LineNo, ColNo: t.ValueColNo}
+	case InlineDictKey:
+		s.expect = &expectation{indent: t.Indent, lineNo: t.LineNo, colNo: t.ValueColNo}
+	case DictKeyMultiline:
+		if s.MinimalMode {
+			return s.MakeParsingError(t, s.ErrCodeFormat,
+				"multi-line key syntax is not allowed in minimal mode")
+		}
+		builder := strings.Builder{}
+		builder.WriteString(key)
+		for {
+			if err := s.advance(); err != nil {
+				return err
+			}
+			if s.token.TokenType != DictKeyMultiline || s.token.Indent != t.Indent {
+				break
+			}
+			builder.WriteRune('\n')
+			builder.WriteString(allowVoid(s.token.Content, 0))
+		}
+		key = builder.String()
+		s.expect = &expectation{indent: t.Indent, required: true}
+	default:
+		return s.MakeParsingError(t, s.ErrCodeFormat, "dict item expected")
+	}
+	if top.keys[key] {
+		return s.MakeParsingError(t, s.ErrCodeFormat, fmt.Sprintf("duplicate key: %s", key))
+	}
+	top.keys[key] = true
+	s.emit(EventKey, key, t.LineNo, t.Indent+1)
+	if value != nil {
+		s.pending = append(s.pending, *value)
+	}
+	if t.TokenType == DictKeyMultiline {
+		return nil // already advanced past the key
+	}
+	return s.advance()
+}
+
+// advance reads the next token from the scanner.
+func (s *Streamer) advance() error {
+	s.token = s.Sc.NextToken()
+	return s.token.Error
+}
+
+// close produces the end event for the innermost open list or dict.
+func (s *Streamer) close() {
+	top := s.open[len(s.open)-1]
+	s.open[len(s.open)-1] = openItem{} // release the key set of a closed dict
+	s.open = s.open[:len(s.open)-1]
+	kind := EventListEnd
+	if top.dict {
+		kind = EventDictEnd
+	}
+	s.emit(kind, "", top.lineNo, top.colNo)
+	s.itemDone()
+}
+
+// itemDone records the completion of an item, which completes the document if it
+// is the top-level item.
+func (s *Streamer) itemDone() {
+	if len(s.open) == 0 {
+		s.done = true
+	}
+}
+
+func (s *Streamer) emit(kind EventKind, value string, lineNo, colNo int) {
+	s.pending = append(s.pending, Event{Kind: kind, Value: value, LineNo: lineNo, ColNo: colNo})
+}
+
+// emitNode produces the events for a node hierarchy.
+func (s *Streamer) emitNode(n *Node) {
+	switch n.Kind {
+	case ListNode:
+		s.emit(EventListStart, "", n.LineNo, n.ColNo)
+		for _, item := range n.Items {
+			s.emitNode(item)
+		}
+		s.emit(EventListEnd, "", n.LineNo, n.ColNo)
+	case DictNode:
+		s.emit(EventDictStart, "", n.LineNo, n.ColNo)
+		for i, item := range n.Items {
+			s.emit(EventKey, n.Keys[i].Value, n.Keys[i].LineNo, n.Keys[i].ColNo)
+			s.emitNode(item)
+		}
+		s.emit(EventDictEnd, "", n.LineNo, n.ColNo)
+	default:
+		s.emit(EventString, n.Value, n.LineNo, n.ColNo)
+	}
+}
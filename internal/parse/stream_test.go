@@ -0,0 +1,145 @@
+package parse
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// streamNode assembles the events of a streamer into a node hierarchy.
+func streamNode(s *Streamer) (*Node, error) {
+	event, err := s.Next()
+	if err != nil || event.Kind == EventEOF {
+		return nil, err
+	}
+	node := &Node{LineNo: event.LineNo, ColNo: event.ColNo}
+	switch event.Kind {
+	case EventString:
+		node.Kind, node.Value = StringNode, event.Value
+		return node, nil
+	case EventDictStart:
+		node.Kind = DictNode
+	default:
+		node.Kind = ListNode
+	}
+	for {
+		event, err := s.Peek()
+		if err != nil {
+			return nil, err
+		}
+		if event.Kind == EventListEnd || event.Kind == EventDictEnd {
+			_, err = s.Next()
+			return node, err
+		}
+		if event.Kind == EventKey {
+			s.Next()
+			node.Keys = append(node.Keys, NewStringNode(event.Value, event.LineNo, event.ColNo))
+		}
+		item, err := streamNode(s)
+		if err != nil {
+			return nil, err
+		}
+		node.Items = append(node.Items, item)
+	}
+}
+
+func TestStreamerMatchesParser(t *testing.T) {
+	inputs := []string{
+		"",
+		"> just a string\n> on two lines\n",
+		"[a, [b, c], {d: e}]\n",
+		"{a: b, c: [d]}\n",
+		"- a\n-\n  - b\n  -\n- \n-\n",
+		"name: myapp\nhosts:\n  - localhost\n  -\n    host: x\n    port: 1\ntags:\n  [a, b]\ntext:\n  > line one\n  > line two\nempty:\n",
+		": multi\n: line key\n  > value\nk:\n  : another\n  :\n    - x\n",
+		"a:\n  b:\n    c:\n      - d\ne: f\n",
+	}
+	for _, input := range inputs {
+		p := NewParser(testFormatError, testIOError, testParsingError, testErrCodeFormat)
+		want, err := p.ParseNode(strings.NewReader(input), testFormatError, testIOError, testErrCodeFormat+1)
+		if err != nil {
+			t.Fatalf("%q: parser: %v", input, err)
+		}
+		s, err := NewStreamer(strings.NewReader(input), testFormatError, testIOError, testParsingError, testErrCodeFormat, testErrCodeFormat+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := streamNode(s)
+		if err != nil {
+			t.Fatalf("%q: streamer: %v", input, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: streamed nodes differ from parsed nodes:\ngot  %#v\nwant %#v", input, got.Interface(), want.Interface())
+		}
+		if event, err := s.Next(); err != nil || event.Kind != EventEOF {
+			t.Errorf("%q: expected EOF, got %v, %v", input, event, err)
+		}
+	}
+}
+
+func TestStreamerErrors(t *testing.T) {
+	inputs := []string{
+		"- a\n    - b\n",
+		"a: b\na: c\n",
+		"a:\n    b: c\n  d: e\n",
+		"- a\nb: c\n",
+		": key\nb: c\n",
+		"> a\n- b\n",
+	}
+	for _, input := range inputs {
+		s, err := NewStreamer(strings.NewReader(input), testFormatError, testIOError, testParsingError, testErrCodeFormat, testErrCodeFormat+1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for {
+			event, err := s.Next()
+			if err != nil {
+				break
+			}
+			if event.Kind == EventEOF {
+				t.Errorf("%q: expected an error", input)
+				break
+			}
+		}
+	}
+}
+
+func TestStreamerReleasesClosedDicts(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&b, "item%d:\n", i)
+		for j := 0; j < 10; j++ {
+			fmt.Fprintf(&b, "  key%d:\n    value: %d\n", j, j)
+		}
+	}
+	s, err := NewStreamer(strings.NewReader(b.String()), testFormatError, testIOError, testParsingError, testErrCodeFormat, testErrCodeFormat+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ends := 0
+	for {
+		event, err := s.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Kind == EventEOF {
+			break
+		}
+		if len(s.open) > 3 {
+			t.Fatalf("%d levels open, expected at most 3", len(s.open))
+		}
+		if event.Kind != EventDictEnd {
+			continue
+		}
+		ends++
+		for _, item := range s.open[len(s.open):cap(s.open)] {
+			if item.keys != nil {
+				t.Fatalf("key set of a closed dict is retained after %d dict ends", ends)
+			}
+		}
+	}
+	if ends != 1000*11+1 {
+		t.Errorf("got %d dict ends, expected %d", ends, 1000*11+1)
+	}
+}
@@ -10,6 +10,7 @@ type Token struct {
 	TokenType     TokenType // type of token
 	Indent        int       // amount of indent of this line
 	Content       []string  // UTF-8 content of the line (without indent and item tag)
+	ValueColNo    int       // column at which the last element of Content starts
 	Error         error     // error condition, if any
 }
 
@@ -0,0 +1,96 @@
+package nestedtext
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// MergePolicy determines how decoding treats a slice, map or struct which already
+// holds a value. The policy is selected per kind with the SliceMerge, MapMerge and
+// StructMerge options, and per field with the "merge=" option of the "nt" tag,
+// which takes precedence and applies to the values nested within the field, too.
+// Nested values a tag policy does not apply to, such as the maps within a slice
+// tagged "merge=append", keep the policy selected for their kind.
+type MergePolicy int
+
+const (
+	// MergeReplace discards the existing value. Struct fields absent from the
+	// input are reset to their zero or default value. This is the default for slices.
+	MergeReplace MergePolicy = iota + 1
+
+	// MergeDeep merges the input into the existing value. List items are decoded
+	// onto the slice element with the same index, and further elements are kept;
+	// dict values are decoded onto the map entry or struct field with the same key,
+	// and other entries and fields are kept. This is the default for structs.
+	MergeDeep
+
+	// MergeAppend appends the list items to the existing slice. It only applies to
+	// slices.
+	MergeAppend
+
+	// MergeEntries keeps the map entries absent from the input, and replaces the
+	// values of the others. It only applies to maps, and is their default.
+	MergeEntries
+)
+
+var mergePolicyNames = map[string]MergePolicy{
+	"replace": MergeReplace,
+	"deep":    MergeDeep,
+	"append":  MergeAppend,
+	"entries": MergeEntries,
+}
+
+func (p MergePolicy) String() string {
+	for name, policy := range mergePolicyNames {
+		if policy == p {
+			return name
+		}
+	}
+	return fmt.Sprintf("MergePolicy(%d)", int(p))
+}
+
+// mergePolicy returns the policy for decoding into a value of kind k: the one
+// given by the tag of field fi, if any and applicable to k, otherwise the decoder's
+// policy for k. If neither is set, it returns the default policy for k.
+func (d *Decoder) mergePolicy(k reflect.Kind, fi *fieldInfo) (MergePolicy, error) {
+	policy := d.mergePolicies[k]
+	if fi != nil && fi.merge != "" {
+		tagPolicy, ok := mergePolicyNames[fi.merge]
+		if !ok {
+			return 0, makeNestedTextError(ErrCodeUsage,
+				fmt.Sprintf("invalid merge policy %q for field %s", fi.merge, fi.name))
+		}
+		// The tag applies to nested values of other kinds, too, so a policy not
+		// applying to them is only an error for slices
+		if err := checkMergePolicy(k, tagPolicy); err == nil {
+			policy = tagPolicy
+		} else if k == reflect.Slice {
+			return 0, err
+		}
+	}
+	if policy == 0 {
+		switch k {
+		case reflect.Slice:
+			return MergeReplace, nil
+		case reflect.Map:
+			return MergeEntries, nil
+		case reflect.Struct:
+			return MergeDeep, nil
+		}
+	}
+	return policy, nil
+}
+
+// checkMergePolicy reports an error if policy does not apply to values of kind k.
+func checkMergePolicy(k reflect.Kind, policy MergePolicy) error {
+	switch {
+	case policy == 0, policy == MergeReplace, policy == MergeDeep:
+		return nil
+	case policy == MergeAppend && k == reflect.Slice:
+		return nil
+	case policy == MergeEntries && k == reflect.Map:
+		return nil
+	}
+	return makeNestedTextError(ErrCodeUsage,
+		fmt.Sprintf("merge policy %v does not apply to %s values", policy, k))
+}
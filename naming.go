@@ -0,0 +1,86 @@
+package nestedtext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NamingStrategy derives the dict key of a struct field from its Go field name,
+// for fields whose tag does not give a name. It is set with the FieldNaming and
+// WithFieldNaming options; without one, the Go field name is used as it is.
+//
+// Struct metadata is cached per strategy if the strategy is comparable, as the
+// predefined ones are.
+type NamingStrategy interface {
+	FieldKey(name string) string
+}
+
+// NamingFunc adapts a function to the NamingStrategy interface.
+type NamingFunc func(name string) string
+
+// FieldKey returns f(name).
+func (f NamingFunc) FieldKey(name string) string {
+	return f(name)
+}
+
+// Predefined naming strategies. Go field names are split into words at changes of
+// case, keeping acronyms together, so that "HTTPServerPort" consists of the words
+// "http", "server" and "port".
+var (
+	SnakeCase      NamingStrategy = wordNaming{separator: "_"}   // "http_server_port"
+	KebabCase      NamingStrategy = wordNaming{separator: "-"}   // "http-server-port"
+	SpaceSeparated NamingStrategy = wordNaming{separator: " "}   // "http server port"
+	LowerCamelCase NamingStrategy = wordNaming{lowerCamel: true} // "httpServerPort"
+)
+
+// wordNaming joins the lower-cased words of a field name with a separator, or
+// into lower camel case.
+type wordNaming struct {
+	separator  string
+	lowerCamel bool
+}
+
+func (w wordNaming) FieldKey(name string) string {
+	words := splitWords(name)
+	for i, word := range words {
+		word = strings.ToLower(word)
+		if w.lowerCamel && i > 0 {
+			r := []rune(word)
+			r[0] = unicode.ToUpper(r[0])
+			word = string(r)
+		}
+		words[i] = word
+	}
+	return strings.Join(words, w.separator)
+}
+
+// splitWords splits a Go identifier into words. A word starts at an upper case
+// letter following a lower case letter or digit, and at the last upper case letter
+// of an acronym followed by a lower case letter. Underscores separate words, too.
+func splitWords(name string) []string {
+	var words []string
+	r := []rune(name)
+	start := 0
+	for i := 0; i < len(r); i++ {
+		if r[i] == '_' {
+			if i > start {
+				words = append(words, string(r[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+		if i == start || !unicode.IsUpper(r[i]) {
+			continue
+		}
+		prev := r[i-1]
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) ||
+			(unicode.IsUpper(prev) && i+1 < len(r) && unicode.IsLower(r[i+1])) {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	if start < len(r) {
+		words = append(words, string(r[start:]))
+	}
+	return words
+}
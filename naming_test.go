@@ -0,0 +1,153 @@
+package nestedtext
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNamingStrategies(t *testing.T) {
+	tests := []struct {
+		name                       string
+		snake, kebab, space, camel string
+	}{
+		{"AdditionalRoles", "additional_roles", "additional-roles", "additional roles", "additionalRoles"},
+		{"HTTPServerPort", "http_server_port", "http-server-port", "http server port", "httpServerPort"},
+		{"UserID", "user_id", "user-id", "user id", "userId"},
+		{"Port8080Enabled", "port8080_enabled", "port8080-enabled", "port8080 enabled", "port8080Enabled"},
+		{"Max_Size", "max_size", "max-size", "max size", "maxSize"},
+		{"Name", "name", "name", "name", "name"},
+	}
+	for _, tt := range tests {
+		got := []string{SnakeCase.FieldKey(tt.name), KebabCase.FieldKey(tt.name),
+			SpaceSeparated.FieldKey(tt.name), LowerCamelCase.FieldKey(tt.name)}
+		want := []string{tt.snake, tt.kebab, tt.space, tt.camel}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, want)
+		}
+	}
+}
+
+type contact struct {
+	Name            string
+	Email           string `nt:"email,alias=e-mail|mail"`
+	AdditionalRoles []string
+	Phone           map[string]string
+}
+
+func TestUnmarshalFieldNaming(t *testing.T) {
+	input := `name: Katheryn McDaniel
+phone:
+  cell: 1-210-555-5297
+e-mail: KateMcD@aol.com
+additional roles:
+  - board member
+`
+	var c contact
+	if err := Unmarshal([]byte(input), &c, FieldNaming(SpaceSeparated), DisallowUnknownFields()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := contact{
+		Name:            "Katheryn McDaniel",
+		Email:           "KateMcD@aol.com",
+		AdditionalRoles: []string{"board member"},
+		Phone:           map[string]string{"cell": "1-210-555-5297"},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	// Without a strategy, the derived key does not match
+	err := Unmarshal([]byte(input), &contact{}, DisallowUnknownFields())
+	var unknown *UnknownFieldError
+	if !errors.As(err, &unknown) || unknown.Key != "additional roles" {
+		t.Errorf("without FieldNaming: got %v, want *UnknownFieldError for \"additional roles\"", err)
+	}
+
+	// A key and an alias of the same field conflict
+	err = Unmarshal([]byte("email: a@b.c\nmail: d@e.f\n"), &contact{})
+	var conflict *KeyConflictError
+	if !errors.As(err, &conflict) || conflict.Key != "mail" || conflict.Previous != "email" {
+		t.Errorf("got %v, want *KeyConflictError between email and mail", err)
+	}
+
+	// Naming functions need not be comparable
+	shout := NamingFunc(strings.ToUpper)
+	if err := Unmarshal([]byte("NAME: x\n"), &c, FieldNaming(shout), ExactKeys()); err != nil || c.Name != "x" {
+		t.Errorf("with NamingFunc: Name = %q, err = %v", c.Name, err)
+	}
+}
+
+func TestEncodeFieldNaming(t *testing.T) {
+	c := contact{
+		Name:            "Katheryn McDaniel",
+		Email:           "KateMcD@aol.com",
+		AdditionalRoles: []string{"board member", "treasurer"},
+		Phone:           map[string]string{"cell": "1-210-555-5297"},
+	}
+	b, err := Marshal(c, WithFieldNaming(KebabCase), WithFlowWidth(0))
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `additional-roles:
+  - board member
+  - treasurer
+email: KateMcD@aol.com
+name: Katheryn McDaniel
+phone:
+  cell: 1-210-555-5297
+`
+	if string(b) != want {
+		t.Errorf("Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	var back contact
+	if err := Unmarshal(b, &back, FieldNaming(KebabCase)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if !reflect.DeepEqual(back, c) {
+		t.Errorf("round trip = %+v, want %+v", back, c)
+	}
+}
+
+func TestNamingFuncCachesPerDecoder(t *testing.T) {
+	calls := 0
+	naming := NamingFunc(func(name string) string {
+		calls++
+		return strings.ToLower(name)
+	})
+	type Item struct {
+		Name  string
+		Count int
+	}
+	var items []Item
+	input := strings.Repeat("-\n  name: a\n  count: 1\n", 10)
+	if err := Unmarshal([]byte(input), &items, FieldNaming(naming)); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(items) != 10 || calls != 2 {
+		t.Errorf("got %d items and %d naming calls, want 10 and 2", len(items), calls)
+	}
+	calls = 0
+	if _, err := Marshal(items, WithFieldNaming(naming)); err != nil || calls != 2 {
+		t.Errorf("Marshal made %d naming calls, want 2; err = %v", calls, err)
+	}
+}
+
+func BenchmarkUnmarshalNamingFunc(b *testing.B) {
+	type Server struct {
+		HostName   string
+		ListenPort int
+		MaxConns   int
+	}
+	input := []byte(strings.Repeat("-\n  host_name: a\n  listen_port: 80\n  max_conns: 10\n", 100))
+	naming := FieldNaming(NamingFunc(SnakeCase.FieldKey))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var servers []Server
+		if err := Unmarshal(input, &servers, naming); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
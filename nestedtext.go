@@ -41,14 +41,16 @@ package nestedtext
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // --- Error type ------------------------------------------------------------
 
 // NestedTextError is a custom error type for working with NestedText instances.
 type NestedTextError struct {
-	Code         int // error code
-	Line, Column int // error position
+	Code         int    // error code
+	Line, Column int    // error position
+	Filename     string // name of the input source, if known
 	msg          string
 	wrappedError error
 }
@@ -71,8 +73,16 @@ const (
 	ErrCodeUnmarshalType // type mismatch during unmarshal
 )
 
-// Error produces an error message from a NestedText error.
+// Error produces an error message from a NestedText error. The message starts with
+// the position as "file:line:col" if the name of the input source is known, and as
+// "[line,col]" otherwise.
 func (e NestedTextError) Error() string {
+	switch {
+	case e.Filename != "" && e.Line > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", e.Filename, e.Line, e.Column, e.msg)
+	case e.Filename != "":
+		return fmt.Sprintf("%s: %s", e.Filename, e.msg)
+	}
 	return fmt.Sprintf("[%d,%d] %s", e.Line, e.Column, e.msg)
 }
 
@@ -104,17 +114,47 @@ type Unmarshaler interface {
 	UnmarshalNT(value interface{}) error
 }
 
+// NodeUnmarshaler is the interface implemented by types that can unmarshal a
+// NestedText item of themselves, taking the position of the item and of its
+// children into account. Errors created with Node.Errorf, as well as other
+// NestedTextErrors, are returned to the caller as they are; other errors are
+// wrapped into a NestedTextError located at the position of the node.
+// NodeUnmarshaler takes precedence over Unmarshaler.
+type NodeUnmarshaler interface {
+	UnmarshalNTNode(node *Node) error
+}
+
+// Validator is the interface implemented by types that can check their own value
+// after decoding. ValidateNT is called bottom-up, so the values nested within a
+// value have been decoded and validated before. A non-nil error is reported as a
+// *ValidationError carrying the key path and position of the value.
+type Validator interface {
+	ValidateNT() error
+}
+
 // --- Struct tag parsing -----------------------------------------------------
 
 // ntTagOptions holds the parsed options from a struct field's "nt" tag.
 type ntTagOptions struct {
-	name      string // custom field name (empty if not specified)
-	omitEmpty bool   // omitempty option present
-	ignore    bool   // field should be ignored (tag == "-")
+	name         string   // custom field name (empty if not specified)
+	aliases      []string // alternative names given by the alias option
+	omitEmpty    bool     // omitempty option present
+	required     bool     // required option present
+	hasDefault   bool     // default option present
+	defaultValue string   // value of the default option, in NestedText notation
+	layout       string   // time layout given by the layout option
+	inline       bool     // inline option present
+	bytes        bool     // bytes option present
+	binary       string   // encoding of byte slices given by the hex or base64url option
+	merge        string   // merge policy given by the merge option
+	ignore       bool     // field should be ignored (tag == "-")
 }
 
 // parseNTTag parses a struct field's "nt" tag and returns the options.
-// Tag format: "name,omitempty" or "-" to ignore the field.
+// Tag format: "name,omitempty,required,inline,bytes,hex,base64url,alias=a|b,default=value,layout=2006-01-02,merge=append" or
+// "-" to ignore the field.
+// A default value may be an inline list or dict, e.g. "default=[a, b]"; commas
+// inside brackets do not separate options.
 func parseNTTag(tag string) ntTagOptions {
 	var opts ntTagOptions
 	if tag == "-" {
@@ -124,14 +164,78 @@ func parseNTTag(tag string) ntTagOptions {
 	if tag == "" {
 		return opts
 	}
-	parts := strings.Split(tag, ",")
+	parts := splitTagOptions(tag)
 	if parts[0] != "" {
 		opts.name = parts[0]
 	}
 	for _, opt := range parts[1:] {
-		if opt == "omitempty" {
+		switch {
+		case opt == "omitempty":
 			opts.omitEmpty = true
+		case opt == "required":
+			opts.required = true
+		case opt == "inline":
+			opts.inline = true
+		case opt == "bytes":
+			opts.bytes = true
+		case opt == "hex", opt == "base64url":
+			opts.binary = opt
+		case strings.HasPrefix(opt, "default="):
+			opts.hasDefault = true
+			opts.defaultValue = strings.TrimPrefix(opt, "default=")
+		case strings.HasPrefix(opt, "alias="):
+			opts.aliases = append(opts.aliases, strings.Split(strings.TrimPrefix(opt, "alias="), "|")...)
+		case strings.HasPrefix(opt, "merge="):
+			opts.merge = strings.TrimPrefix(opt, "merge=")
+		case strings.HasPrefix(opt, "layout="):
+			opts.layout = strings.TrimPrefix(opt, "layout=")
+			if named, ok := namedTimeLayouts[opts.layout]; ok {
+				opts.layout = named
+			}
 		}
 	}
 	return opts
 }
+
+// namedTimeLayouts holds the layouts which may be referred to by name in a
+// layout option. This allows for layouts containing commas, which could not be
+// given literally.
+var namedTimeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"DateOnly":    "2006-01-02",
+	"TimeOnly":    "15:04:05",
+}
+
+// splitTagOptions splits a tag at commas which are not enclosed in brackets
+// or braces.
+func splitTagOptions(tag string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, ch := range tag {
+		switch ch {
+		case '[', '{':
+			depth++
+		case ']', '}':
+			if depth > 0 {
+				depth--
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, tag[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, tag[start:])
+}
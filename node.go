@@ -0,0 +1,79 @@
+package nestedtext
+
+import (
+	"fmt"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// NodeKind is the kind of a NestedText item.
+type NodeKind int8
+
+const (
+	StringNode NodeKind = iota // string item
+	ListNode                   // list item
+	DictNode                   // dict item
+)
+
+// Node is a NestedText item together with its position in the input, as passed to
+// NodeUnmarshaler. Strings carry their content in Value. Lists and dicts carry their
+// children in Items, in document order; dicts additionally carry their keys as
+// string nodes in Keys, parallel to Items, so that keys have positions as well.
+type Node struct {
+	Kind         NodeKind
+	Value        string  // content of a string item
+	Items        []*Node // list items or dict values
+	Keys         []*Node // dict keys, nil for strings and lists
+	Line, Column int     // start of the item in the input
+}
+
+// newNode converts an internal node hierarchy into its public counterpart.
+func newNode(n *parse.Node) *Node {
+	node := &Node{
+		Kind:   NodeKind(n.Kind),
+		Value:  n.Value,
+		Line:   n.LineNo,
+		Column: n.ColNo,
+	}
+	if n.Items != nil {
+		node.Items = make([]*Node, len(n.Items))
+		for i, item := range n.Items {
+			node.Items[i] = newNode(item)
+		}
+	}
+	if n.Keys != nil {
+		node.Keys = make([]*Node, len(n.Keys))
+		for i, key := range n.Keys {
+			node.Keys[i] = newNode(key)
+		}
+	}
+	return node
+}
+
+// Interface converts n into the plain representation passed to Unmarshaler:
+// string, []interface{} or map[string]interface{}.
+func (n *Node) Interface() interface{} {
+	switch n.Kind {
+	case ListNode:
+		list := make([]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			list[i] = item.Interface()
+		}
+		return list
+	case DictNode:
+		dict := make(map[string]interface{}, len(n.Items))
+		for i, item := range n.Items {
+			dict[n.Keys[i].Value] = item.Interface()
+		}
+		return dict
+	}
+	return n.Value
+}
+
+// Errorf returns a NestedTextError with code ErrCodeUnmarshal, located at the
+// position of n, with a message formatted as with fmt.Sprintf.
+func (n *Node) Errorf(format string, args ...interface{}) error {
+	err := makeNestedTextError(ErrCodeUnmarshal, fmt.Sprintf(format, args...))
+	err.Line, err.Column = n.Line, n.Column
+	return err
+}
@@ -0,0 +1,128 @@
+package nestedtext
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// endpoints demonstrates the NodeUnmarshaler interface: a dict of names to
+// host:port strings, rejecting entries without a port at their position.
+type endpoints map[string]string
+
+func (e *endpoints) UnmarshalNTNode(n *Node) error {
+	if n.Kind != DictNode {
+		return n.Errorf("expected dict of endpoints")
+	}
+	*e = make(endpoints)
+	for i, item := range n.Items {
+		key := n.Keys[i]
+		if strings.HasPrefix(key.Value, "_") {
+			return errors.New("reserved endpoint name")
+		}
+		if item.Kind != StringNode || !strings.Contains(item.Value, ":") {
+			return item.Errorf("endpoint %s: missing port", key.Value)
+		}
+		(*e)[key.Value] = item.Value
+	}
+	return nil
+}
+
+// nodeAndValue implements both unmarshaling interfaces.
+type nodeAndValue struct {
+	via string
+}
+
+func (v *nodeAndValue) UnmarshalNTNode(n *Node) error {
+	v.via = "UnmarshalNTNode"
+	return nil
+}
+
+func (v *nodeAndValue) UnmarshalNT(value interface{}) error {
+	v.via = "UnmarshalNT"
+	return nil
+}
+
+func TestUnmarshalNodeUnmarshaler(t *testing.T) {
+	type Config struct {
+		Name      string
+		Endpoints endpoints
+	}
+
+	var config Config
+	input := "name: app\nendpoints:\n  api: localhost:8080\n  db: localhost:5432\n"
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := endpoints{"api": "localhost:8080", "db": "localhost:5432"}
+	if !reflect.DeepEqual(config.Endpoints, want) {
+		t.Errorf("Endpoints = %v, want %v", config.Endpoints, want)
+	}
+
+	tests := []struct {
+		name         string
+		input        string
+		line, column int
+		msg          string
+		wrapped      bool
+	}{
+		{"Errorf", "endpoints:\n  api: localhost:8080\n  db: localhost\n", 3, 7, "endpoint db: missing port", false},
+		{"Errorf on item", "endpoints: none\n", 1, 12, "expected dict of endpoints", false},
+		{"plain error", "endpoints:\n  _api: localhost:80\n", 2, 3, "reserved endpoint name", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Unmarshal([]byte(tt.input), &Config{})
+			var nte NestedTextError
+			if !errors.As(err, &nte) {
+				t.Fatalf("got %v, want NestedTextError", err)
+			}
+			if nte.Code != ErrCodeUnmarshal || nte.Line != tt.line || nte.Column != tt.column {
+				t.Errorf("got code %d at %d:%d, want %d at %d:%d",
+					nte.Code, nte.Line, nte.Column, ErrCodeUnmarshal, tt.line, tt.column)
+			}
+			if !strings.Contains(err.Error(), tt.msg) {
+				t.Errorf("error %q does not mention %q", err, tt.msg)
+			}
+			if (errors.Unwrap(nte) != nil) != tt.wrapped {
+				t.Errorf("Unwrap() = %v, want wrapped error: %v", errors.Unwrap(nte), tt.wrapped)
+			}
+		})
+	}
+
+	var v nodeAndValue
+	if err := Unmarshal([]byte("> x"), &v); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if v.via != "UnmarshalNTNode" {
+		t.Errorf("decoded via %s, want UnmarshalNTNode", v.via)
+	}
+}
+
+func TestNodeInterface(t *testing.T) {
+	var got interface{}
+	var n *Node
+	capture := nodeFunc(func(node *Node) error {
+		n = node
+		got = node.Interface()
+		return nil
+	})
+	if err := Unmarshal([]byte("a:\n  - x\n  - y\nb: z\n"), &capture); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	want := map[string]interface{}{"a": []interface{}{"x", "y"}, "b": "z"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Interface() = %v, want %v", got, want)
+	}
+	if b := n.Keys[1]; b.Value != "b" || b.Line != 4 || b.Column != 1 {
+		t.Errorf("key b = %q at %d:%d, want \"b\" at 4:1", b.Value, b.Line, b.Column)
+	}
+	if y := n.Items[0].Items[1]; y.Value != "y" || y.Line != 3 || y.Column != 5 {
+		t.Errorf("item y = %q at %d:%d, want \"y\" at 3:5", y.Value, y.Line, y.Column)
+	}
+}
+
+type nodeFunc func(*Node) error
+
+func (f *nodeFunc) UnmarshalNTNode(n *Node) error { return (*f)(n) }
@@ -0,0 +1,151 @@
+package nestedtext
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// Arbitrary-precision numbers are decoded from and encoded to their decimal
+// representation directly, without going through float64, so that values such as
+// amounts of money survive a round trip exactly.
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+)
+
+// decodeBigNumber decodes a NestedText string into a big.Int, big.Float or big.Rat.
+// Integers are decimal unless NumericLiterals is in effect. Floats keep the
+// precision of v if it has one, otherwise they get a precision sufficient for the
+// digits given, and at least that of a float64. Rationals accept fractions such
+// as "1/3" as well as decimals such as "19.99".
+func (d *Decoder) decodeBigNumber(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	s := n.Value
+	ok := false
+	switch x := v.Addr().Interface().(type) {
+	case *big.Int:
+		_, ok = x.SetString(d.intLiteral(s))
+	case *big.Float:
+		prec := x.Prec()
+		if prec == 0 {
+			prec = decimalPrecision(s)
+		}
+		f, _, err := big.ParseFloat(s, 10, prec, x.Mode())
+		if ok = err == nil; ok {
+			x.Set(f)
+		}
+	case *big.Rat:
+		_, ok = x.SetString(s)
+	}
+	if !ok {
+		return &UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", s),
+			Type:  v.Type(),
+		}
+	}
+	return nil
+}
+
+// decimalPrecision returns the binary precision needed to hold the significant
+// digits of decimal number s, but at least 53 bits, the precision of a float64.
+func decimalPrecision(s string) uint {
+	mantissa := strings.ToLower(s)
+	if i := strings.IndexByte(mantissa, 'e'); i >= 0 {
+		mantissa = mantissa[:i]
+	}
+	digits := 0
+	for _, c := range strings.TrimLeft(mantissa, "+-0.") {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+	prec := uint(math.Ceil(float64(digits) * math.Log2(10)))
+	if prec < 53 {
+		return 53
+	}
+	return prec
+}
+
+// formatBigFloat formats x as the shortest decimal that parses back to x at its
+// precision. Following encoding/json, exponent notation is only used for very small
+// and very large magnitudes.
+func formatBigFloat(x *big.Float) string {
+	if x.IsInf() {
+		return x.Text('g', -1)
+	}
+	exp := x.MantExp(nil)
+	if x.Sign() != 0 && (exp < -19 || exp > 70) { // about 1e-6 and 1e21
+		return x.Text('g', -1)
+	}
+	return x.Text('f', -1)
+}
+
+// formatBigRat formats x as an integer or a decimal if it has a finite decimal
+// representation, such as "19.99", otherwise as a fraction, such as "1/3".
+func formatBigRat(x *big.Rat) string {
+	if x.IsInt() {
+		return x.Num().String()
+	}
+	// x has a finite decimal representation iff its denominator has no prime
+	// factors other than 2 and 5. The number of decimals needed is the larger of
+	// their multiplicities.
+	denom := new(big.Int).Set(x.Denom())
+	twos := denom.TrailingZeroBits()
+	denom.Rsh(denom, twos)
+	fives := uint(0)
+	five, rem := big.NewInt(5), new(big.Int)
+	for {
+		q, r := new(big.Int).QuoRem(denom, five, rem)
+		if r.Sign() != 0 {
+			break
+		}
+		denom = q
+		fives++
+	}
+	if !denom.IsInt64() || denom.Int64() != 1 {
+		return x.String()
+	}
+	if fives > twos {
+		twos = fives
+	}
+	return x.FloatString(int(twos))
+}
+
+// decodeComplex decodes a NestedText string into a Go complex type, such as "1+2i".
+func decodeComplex(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.StringNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	c, err := strconv.ParseComplex(n.Value, v.Type().Bits())
+	if err != nil {
+		return &UnmarshalTypeError{
+			Value: fmt.Sprintf("string %q", n.Value),
+			Type:  v.Type(),
+		}
+	}
+	v.SetComplex(c)
+	return nil
+}
+
+// formatComplex formats c as the shortest representation that parses back to c
+// with the given bit size, without the parentheses added by strconv.
+func formatComplex(c complex128, bitSize int) string {
+	s := strconv.FormatComplex(c, 'g', -1, bitSize)
+	return s[1 : len(s)-1]
+}
@@ -0,0 +1,111 @@
+package nestedtext
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestBigNumbersRoundTrip(t *testing.T) {
+	type Invoice struct {
+		Total     big.Rat
+		Tax       *big.Rat
+		Rate      *big.Float
+		Units     *big.Int
+		Shares    big.Rat
+		Impedance complex128
+		Phase     complex64
+		Lines     map[string]*big.Rat
+	}
+
+	input := `Total: 1234567890123456789.99
+Tax: 0.07
+Rate: 3.14159265358979323846264338327950288
+Units: 123456789012345678901234567890
+Shares: 1/3
+Impedance: 50-25.5i
+Phase: 1e-3i
+Lines:
+  a: 0.10
+  b: -12
+`
+	var inv Invoice
+	if err := Unmarshal([]byte(input), &inv); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want, _ := new(big.Rat).SetString("123456789012345678999/100"); inv.Total.Cmp(want) != 0 {
+		t.Errorf("Total = %v, want %v", &inv.Total, want)
+	}
+	if inv.Rate.Prec() <= 64 {
+		t.Errorf("Rate precision = %d, want more than 64 bits for 36 digits", inv.Rate.Prec())
+	}
+	if inv.Impedance != complex(50, -25.5) || inv.Phase != complex(0, 0.001) {
+		t.Errorf("Impedance, Phase = %v, %v", inv.Impedance, inv.Phase)
+	}
+
+	b, err := Marshal(inv)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := `Impedance: 50-25.5i
+Lines:
+  a: 0.1
+  b: -12
+Phase: 0+0.001i
+Rate: 3.14159265358979323846264338327950288
+Shares: 1/3
+Tax: 0.07
+Total: 1234567890123456789.99
+Units: 123456789012345678901234567890
+`
+	if string(b) != want {
+		t.Errorf("Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	var again Invoice
+	if err := Unmarshal(b, &again); err != nil {
+		t.Fatalf("Unmarshal of encoding failed: %v", err)
+	}
+	if again.Total.Cmp(&inv.Total) != 0 || again.Rate.Cmp(inv.Rate) != 0 || again.Shares.Cmp(&inv.Shares) != 0 {
+		t.Errorf("round trip changed values: %v %v %v", &again.Total, again.Rate, &again.Shares)
+	}
+}
+
+func TestBigNumberFormatting(t *testing.T) {
+	tests := []struct {
+		v    interface{}
+		want string
+	}{
+		{big.NewRat(5, 1), "> 5\n"},
+		{big.NewRat(-1, 8), "> -0.125\n"},
+		{big.NewRat(2, 3), "> 2/3\n"},
+		{big.NewFloat(1e6), "> 1000000\n"},
+		{big.NewFloat(1e30), "> 1e+30\n"},
+		{complex64(1.5), "> 1.5+0i\n"},
+	}
+	for _, tt := range tests {
+		b, err := Marshal(tt.v)
+		if err != nil {
+			t.Fatalf("Marshal(%v) failed: %v", tt.v, err)
+		}
+		if string(b) != tt.want {
+			t.Errorf("Marshal(%v) = %q, want %q", tt.v, b, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalBigNumberErrors(t *testing.T) {
+	var x struct {
+		N *big.Int
+		C complex64
+	}
+	for _, input := range []string{"n: 1.5", "n: 0x10", "c: 1+", "n:\n  - 1"} {
+		var typeErr *UnmarshalTypeError
+		if err := Unmarshal([]byte(input), &x); !errors.As(err, &typeErr) {
+			t.Errorf("Unmarshal(%q) = %v, want *UnmarshalTypeError", input, err)
+		}
+	}
+	if err := Unmarshal([]byte("n: 0x10"), &x, NumericLiterals()); err != nil || x.N.Int64() != 16 {
+		t.Errorf("with NumericLiterals: N = %v, err = %v", x.N, err)
+	}
+}
@@ -0,0 +1,103 @@
+package nestedtext
+
+import (
+	"reflect"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// OrderedMap is a dict which retains the order of its keys. It decodes from a
+// NestedText dict in document order and encodes in the order of its keys, rather
+// than sorted like a Go map. Values are strings, []interface{} and *OrderedMap
+// values when decoded, but may be of any encodable type when encoding.
+//
+// The zero value is an empty map ready to use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+var orderedMapType = reflect.TypeOf(OrderedMap{})
+
+// NewOrderedMap returns an empty OrderedMap.
+func NewOrderedMap() *OrderedMap {
+	return &OrderedMap{}
+}
+
+// Get returns the value stored under key and reports whether it is present.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	value, ok := m.values[key]
+	return value, ok
+}
+
+// Set stores value under key. A new key is added after the existing ones; an
+// existing key keeps its position.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Delete removes key and its value, if present.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns the keys in order. The result must not be modified.
+func (m *OrderedMap) Keys() []string {
+	return m.keys
+}
+
+// Len returns the number of keys.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+// decodeOrderedMap decodes a NestedText dict into an OrderedMap, replacing its
+// contents. Nested dicts are decoded into *OrderedMap values as well.
+func (d *Decoder) decodeOrderedMap(n *parse.Node, v reflect.Value) error {
+	if n.Kind != parse.DictNode {
+		return &UnmarshalTypeError{
+			Value: typeNameOf(n),
+			Type:  v.Type(),
+		}
+	}
+	m := d.interfaceValue(n, d.inferRules != nil, true).(*OrderedMap)
+	v.Set(reflect.ValueOf(*m))
+	return nil
+}
+
+// encodeOrderedMap encodes m as a NestedText dict, in the order of its keys.
+func (enc *Encoder) encodeOrderedMap(indent int, m *OrderedMap, bcnt int, err error) (int, error) {
+	entries, entriesErr := enc.orderedMapEntries(m)
+	if entriesErr != nil {
+		return bcnt, entriesErr
+	}
+	return enc.encodeDict(indent, entries, bcnt, err)
+}
+
+// orderedMapEntries returns the dict entries of m, in the order of its keys.
+func (enc *Encoder) orderedMapEntries(m *OrderedMap) ([]dictEntry, error) {
+	entries := make([]dictEntry, len(m.keys))
+	for i, key := range m.keys {
+		item, _, err := enc.marshalItem(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = dictEntry{key: key, item: item}
+	}
+	return entries, nil
+}
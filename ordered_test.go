@@ -0,0 +1,94 @@
+package nestedtext
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const orderedInput = `zeta: last letter
+alpha:
+  mu: 1
+  beta: 2
+list:
+  -
+    z: a
+    a: z
+middle: m
+`
+
+func TestParseOrderedDicts(t *testing.T) {
+	result, err := Parse(strings.NewReader(orderedInput), OrderedDicts())
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	m, ok := result.(*OrderedMap)
+	if !ok {
+		t.Fatalf("Parse() returned %T, want *OrderedMap", result)
+	}
+	if want := []string{"zeta", "alpha", "list", "middle"}; !reflect.DeepEqual(m.Keys(), want) {
+		t.Errorf("Keys() = %q, want %q", m.Keys(), want)
+	}
+	alpha, _ := m.Get("alpha")
+	if keys := alpha.(*OrderedMap).Keys(); !reflect.DeepEqual(keys, []string{"mu", "beta"}) {
+		t.Errorf("alpha keys = %q", keys)
+	}
+	list, _ := m.Get("list")
+	if keys := list.([]interface{})[0].(*OrderedMap).Keys(); !reflect.DeepEqual(keys, []string{"z", "a"}) {
+		t.Errorf("list[0] keys = %q", keys)
+	}
+
+	// Encoding keeps the order
+	b, err := Marshal(m)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(b) != orderedInput {
+		t.Errorf("Marshal() =\n%s\nwant:\n%s", b, orderedInput)
+	}
+
+	// Together with type inference
+	var v interface{}
+	if err := Unmarshal([]byte(orderedInput), &v, OrderedDicts(), InferTypes()); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	alpha, _ = v.(*OrderedMap).Get("alpha")
+	if mu, _ := alpha.(*OrderedMap).Get("mu"); mu != int64(1) {
+		t.Errorf("alpha.mu = %#v, want int64(1)", mu)
+	}
+}
+
+func TestOrderedMapField(t *testing.T) {
+	type Config struct {
+		Name  string
+		Steps OrderedMap
+		Env   *OrderedMap
+	}
+	input := "name: build\nsteps:\n  fetch: git pull\n  compile: go build\n  test: go test\nenv:\n  PATH: /bin\n  HOME: /root\n"
+	var config Config
+	if err := Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if want := []string{"fetch", "compile", "test"}; !reflect.DeepEqual(config.Steps.Keys(), want) {
+		t.Errorf("Steps.Keys() = %q, want %q", config.Steps.Keys(), want)
+	}
+	if config.Env == nil || !reflect.DeepEqual(config.Env.Keys(), []string{"PATH", "HOME"}) {
+		t.Errorf("Env = %+v", config.Env)
+	}
+
+	config.Steps.Set("lint", "go vet")
+	config.Steps.Set("fetch", "git fetch")
+	config.Steps.Delete("compile")
+	b, err := Marshal(config)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	want := "Env:\n  PATH: /bin\n  HOME: /root\nName: build\nSteps:\n  fetch: git fetch\n  test: go test\n  lint: go vet\n"
+	if string(b) != want {
+		t.Errorf("Marshal() =\n%s\nwant:\n%s", b, want)
+	}
+
+	if err := Unmarshal([]byte("steps: none\n"), &config); err == nil {
+		t.Error("expected error decoding a string into an OrderedMap")
+	}
+}
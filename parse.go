@@ -1,7 +1,9 @@
 package nestedtext
 
 import (
+	"fmt"
 	"io"
+	"reflect"
 
 	"github.com/danielledeleo/nestedtext/internal/parse"
 )
@@ -9,10 +11,13 @@ import (
 // === Top-level API =========================================================
 
 // Parse reads a NestedText input source and outputs a resulting hierarchy of values.
-// Values are stored as strings, []interface{} or map[string]interface{} respectively.
+// Values are stored as strings, []interface{} or map[string]interface{} respectively,
+// unless InferTypes or OrderedDicts is given. With ExpandKeys, dotted keys are
+// expanded into nested dicts.
 // The concrete resulting top-level type depends on the top-level NestedText input type.
 //
-// If a non-nil error is returned, it will be of type NestedTextError.
+// If a non-nil error is returned, it will be of type NestedTextError. With
+// WithSourceName, its Filename names the input source.
 func Parse(r io.Reader, opts ...DecodeOption) (interface{}, error) {
 	// Apply options to a temporary decoder to extract configuration
 	d := &Decoder{}
@@ -21,7 +26,19 @@ func Parse(r io.Reader, opts ...DecodeOption) (interface{}, error) {
 			return nil, err
 		}
 	}
-	return parseWithConfig(r, d.minimalMode)
+	if d.inferRules == nil && !d.orderedDicts && d.expandSeparator == "" {
+		result, err := parseWithConfig(r, d.minimalMode)
+		return result, withSourceName(err, d.sourceName)
+	}
+	root, err := parseNodeWithConfig(r, d.minimalMode)
+	if err == nil && d.expandSeparator != "" {
+		root, err = expandKeys(root, d.expandSeparator)
+	}
+	if err != nil || root == nil {
+		return nil, withSourceName(err, d.sourceName)
+	}
+	d.markKeptStrings(root)
+	return d.interfaceValue(root, d.inferRules != nil, d.orderedDicts), nil
 }
 
 // parseWithConfig is the internal parsing function that accepts configuration directly.
@@ -31,6 +48,14 @@ func parseWithConfig(r io.Reader, minimalMode bool) (interface{}, error) {
 	return p.Parse(r, makeFormatError, wrapIOError, ErrCodeFormatNoInput)
 }
 
+// parseNodeWithConfig parses r into a node hierarchy which retains the input
+// position of every item. An empty document results in a nil node.
+func parseNodeWithConfig(r io.Reader, minimalMode bool) (*parse.Node, error) {
+	p := parse.NewParser(makeFormatError, wrapIOError, makeParsingError, ErrCodeFormat)
+	p.MinimalMode = minimalMode
+	return p.ParseNode(r, makeFormatError, wrapIOError, ErrCodeFormatNoInput)
+}
+
 // --- Parser options --------------------------------------------------------
 
 // DecodeOption configures the behavior of the parsing/decoding process.
@@ -52,6 +77,191 @@ func Minimal() DecodeOption {
 	}
 }
 
+// DisallowUnknownFields returns a DecodeOption that causes decoding into a struct
+// to fail with an *UnknownFieldError if the input contains a dict key which does
+// not match any non-ignored, exported field of the struct. By default, such keys
+// are silently skipped.
+func DisallowUnknownFields() DecodeOption {
+	return func(d *Decoder) error {
+		d.disallowUnknownFields = true
+		return nil
+	}
+}
+
+// ExactKeys returns a DecodeOption that matches dict keys to the names of untagged
+// struct fields case-sensitively. By default, the key "port" matches a field Port.
+// Tag names are always matched exactly.
+func ExactKeys() DecodeOption {
+	return func(d *Decoder) error {
+		d.exactKeys = true
+		return nil
+	}
+}
+
+// OrderedDicts returns a DecodeOption that makes Parse and decoding into an
+// interface{} produce *OrderedMap values for dicts, which retain the order of
+// their keys, instead of map[string]interface{} values.
+func OrderedDicts() DecodeOption {
+	return func(d *Decoder) error {
+		d.orderedDicts = true
+		return nil
+	}
+}
+
+// WithSourceName returns a DecodeOption that names the input source, such as a file
+// name, for error messages. Errors then carry name in their Filename field, and
+// their messages start with "name:line:col". ParseFile, UnmarshalFile and their
+// fs.FS variants set the name of the file.
+func WithSourceName(name string) DecodeOption {
+	return func(d *Decoder) error {
+		d.sourceName = name
+		return nil
+	}
+}
+
+// ExpandKeys returns a DecodeOption that expands dict keys containing separator
+// into nested dicts before decoding, so that "database.host: db1" and
+// "database.port: 5432" are decoded like a dict "database" holding the keys "host"
+// and "port". An empty separator selects ".". Keys with empty parts, such as
+// "a..b", are kept as they are. Keys which would hold both a dict and another value,
+// such as "a: x" and "a.b: y", result in an error located at the later key. This
+// is the counterpart of the WithFlattenKeys encode option.
+func ExpandKeys(separator string) DecodeOption {
+	return func(d *Decoder) error {
+		if separator == "" {
+			separator = "."
+		}
+		d.expandSeparator = separator
+		return nil
+	}
+}
+
+// FieldNaming returns a DecodeOption that derives the keys of struct fields without
+// a tag name from their Go field names using strategy, such as SnakeCase or
+// SpaceSeparated. Keys are matched against the derived key instead of the field
+// name, case-insensitively unless ExactKeys is given.
+func FieldNaming(strategy NamingStrategy) DecodeOption {
+	return func(d *Decoder) error {
+		d.naming = strategy
+		return nil
+	}
+}
+
+// SliceMerge returns a DecodeOption that sets the policy for decoding into slices
+// which already hold elements. The default is MergeReplace; MergeEntries does not
+// apply.
+func SliceMerge(policy MergePolicy) DecodeOption {
+	return mergeOption(reflect.Slice, policy)
+}
+
+// MapMerge returns a DecodeOption that sets the policy for decoding into non-nil
+// maps. The default is MergeEntries; MergeAppend does not apply.
+func MapMerge(policy MergePolicy) DecodeOption {
+	return mergeOption(reflect.Map, policy)
+}
+
+// StructMerge returns a DecodeOption that sets the policy for decoding into
+// structs. The default is MergeDeep; MergeAppend and MergeEntries do not apply.
+func StructMerge(policy MergePolicy) DecodeOption {
+	return mergeOption(reflect.Struct, policy)
+}
+
+func mergeOption(k reflect.Kind, policy MergePolicy) DecodeOption {
+	return func(d *Decoder) error {
+		if err := checkMergePolicy(k, policy); err != nil {
+			return err
+		}
+		if d.mergePolicies == nil {
+			d.mergePolicies = make(map[reflect.Kind]MergePolicy)
+		}
+		d.mergePolicies[k] = policy
+		return nil
+	}
+}
+
+// DecodeFunc returns a DecodeOption that registers fn to decode NestedText strings
+// into values of type T, such as types from other packages, which cannot be given an
+// UnmarshalNT method. Registered functions take precedence over all other ways of
+// decoding a type, and apply to map keys as well. An error returned by fn is
+// reported as an *UnmarshalTypeError wrapping it.
+func DecodeFunc[T any](fn func(string) (T, error)) DecodeOption {
+	return func(d *Decoder) error {
+		if d.converters == nil {
+			d.converters = make(map[reflect.Type]func(string) (reflect.Value, error))
+		}
+		d.converters[reflect.TypeOf((*T)(nil)).Elem()] = func(s string) (reflect.Value, error) {
+			value, err := fn(s)
+			return reflect.ValueOf(&value).Elem(), err
+		}
+		return nil
+	}
+}
+
+// ZeroFillArrays returns a DecodeOption that allows decoding NestedText lists into
+// Go arrays which have more elements than the list has items. The remaining
+// elements are set to their zero value. By default, the list length has to match
+// the array length exactly, otherwise an *ArrayLengthError is returned.
+func ZeroFillArrays() DecodeOption {
+	return func(d *Decoder) error {
+		d.zeroFillArrays = true
+		return nil
+	}
+}
+
+// CollectErrors returns a DecodeOption that causes decoding to carry on past
+// type mismatches, unknown keys and missing required fields. All of them are
+// returned together as an ErrorList. By default, decoding stops at the first error.
+func CollectErrors() DecodeOption {
+	return func(d *Decoder) error {
+		d.collectErrors = true
+		return nil
+	}
+}
+
+// NumericLiterals returns a DecodeOption that accepts Go integer literals for
+// integer values: besides decimal numbers, these are numbers with a base prefix
+// ("0x1F", "0o755", "0b1010"), and numbers with underscores separating digits
+// ("1_000_000"). Unlike in Go, numbers without a prefix are decimal even with
+// leading zeros, so "0755" is 755. By default, integers are parsed as decimal.
+func NumericLiterals() DecodeOption {
+	return func(d *Decoder) error {
+		d.numericLiterals = true
+		return nil
+	}
+}
+
+// BoolStrings returns a DecodeOption that sets the spellings accepted for boolean
+// values, replacing the default "true", "1" and "false", "0". If foldCase is set,
+// spellings are matched case-insensitively. A spelling may not denote both true
+// and false.
+func BoolStrings(trueStrings, falseStrings []string, foldCase bool) DecodeOption {
+	return func(d *Decoder) error {
+		falseOnly := &boolVocabulary{falseStrings: falseStrings, foldCase: foldCase}
+		for _, s := range trueStrings {
+			if _, ok := falseOnly.lookup(s); ok {
+				return makeNestedTextError(ErrCodeUsage, fmt.Sprintf("boolean spelling %q denotes both true and false", s))
+			}
+		}
+		d.bools = &boolVocabulary{
+			trueStrings:  trueStrings,
+			falseStrings: falseStrings,
+			foldCase:     foldCase,
+		}
+		return nil
+	}
+}
+
+// HumanBools returns a DecodeOption that accepts the boolean spellings commonly
+// found in human-written configuration files, regardless of case:
+// "true", "yes", "y", "on", "enabled", "1" and
+// "false", "no", "n", "off", "disabled", "0".
+func HumanBools() DecodeOption {
+	return BoolStrings(
+		[]string{"true", "yes", "y", "on", "enabled", "1"},
+		[]string{"false", "no", "n", "off", "disabled", "0"},
+		true)
+}
+
 // --- Error helper functions for internal package ---------------------------
 
 func makeFormatError(msg string) error {
@@ -0,0 +1,159 @@
+package nestedtext
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// TokenKind is the kind of a Token. The kinds mirror those of the internal
+// streaming parser.
+type TokenKind int8
+
+const (
+	TokenDictStart TokenKind = iota + 1 // start of a dict
+	TokenDictEnd                        // end of a dict
+	TokenListStart                      // start of a list
+	TokenListEnd                        // end of a list
+	TokenKey                            // dict key
+	TokenString                         // string item
+)
+
+var tokenKindNames = [...]string{
+	TokenDictStart: "dict start",
+	TokenDictEnd:   "dict end",
+	TokenListStart: "list start",
+	TokenListEnd:   "list end",
+	TokenKey:       "key",
+	TokenString:    "string",
+}
+
+func (k TokenKind) String() string {
+	if k > 0 && int(k) < len(tokenKindNames) {
+		return tokenKindNames[k]
+	}
+	return fmt.Sprintf("TokenKind(%d)", int(k))
+}
+
+// Token is an element of the token stream returned by Decoder.Token.
+// Keys and strings carry their content in Value. End tokens carry the position of
+// the list or dict they close.
+type Token struct {
+	Kind         TokenKind
+	Value        string
+	Line, Column int // Position of the item in the input
+}
+
+// Token returns the next token of the input, in document order. At the end of the
+// input, Token returns io.EOF.
+//
+// Unlike Decode on its own, Token reads the input line by line and does not build
+// up the item hierarchy, so arbitrarily large inputs can be processed in constant
+// memory (the keys of the dicts not yet closed aside). Token and More may be mixed
+// with calls to Decode, which then decodes the next value of the token stream:
+//
+//	dec := nestedtext.NewDecoder(r)
+//	dec.Token() // list start
+//	for dec.More() {
+//		var rec Record
+//		if err := dec.Decode(&rec); err != nil {
+//			return err
+//		}
+//	}
+//
+// Of the decode options, only Minimal and WithSourceName apply to the tokens.
+func (d *Decoder) Token() (Token, error) {
+	if err := d.startStream(); err != nil {
+		return Token{}, withSourceName(err, d.sourceName)
+	}
+	event, err := d.stream.Next()
+	if err != nil {
+		return Token{}, withSourceName(err, d.sourceName)
+	}
+	if event.Kind == parse.EventEOF {
+		return Token{}, io.EOF
+	}
+	return Token{
+		Kind:   TokenKind(event.Kind),
+		Value:  event.Value,
+		Line:   event.LineNo,
+		Column: event.ColNo,
+	}, nil
+}
+
+// More reports whether there is another element in the current list or dict, or,
+// at the top level, whether there is a value left to read.
+func (d *Decoder) More() bool {
+	if err := d.startStream(); err != nil {
+		return false
+	}
+	event, err := d.stream.Peek()
+	return err == nil && event.Kind != parse.EventEOF &&
+		event.Kind != parse.EventListEnd && event.Kind != parse.EventDictEnd
+}
+
+// startStream switches the decoder to reading the input as a token stream.
+func (d *Decoder) startStream() error {
+	if d.stream != nil {
+		return nil
+	}
+	for _, opt := range d.opts {
+		if err := opt(d); err != nil {
+			return err
+		}
+	}
+	s, err := parse.NewStreamer(d.r, makeFormatError, wrapIOError, makeParsingError, ErrCodeFormat, ErrCodeFormatNoInput)
+	if err != nil {
+		return err
+	}
+	s.MinimalMode = d.minimalMode
+	d.stream = s
+	return nil
+}
+
+// readNode assembles the next value of the token stream into a node hierarchy.
+// At the end of the input, it returns a nil node.
+func (d *Decoder) readNode() (*parse.Node, error) {
+	event, err := d.stream.Next()
+	if err != nil {
+		return nil, err
+	}
+	node := &parse.Node{LineNo: event.LineNo, ColNo: event.ColNo}
+	switch event.Kind {
+	case parse.EventEOF:
+		return nil, nil
+	case parse.EventString:
+		node.Kind, node.Value = parse.StringNode, event.Value
+		return node, nil
+	case parse.EventListStart:
+		node.Kind = parse.ListNode
+	case parse.EventDictStart:
+		node.Kind = parse.DictNode
+	default:
+		err := makeNestedTextError(ErrCodeUnmarshal,
+			fmt.Sprintf("Decode called at %v token; expected a value", TokenKind(event.Kind)))
+		err.Line, err.Column = event.LineNo, event.ColNo
+		return nil, err
+	}
+
+	for {
+		next, err := d.stream.Peek()
+		if err != nil {
+			return nil, err
+		}
+		if next.Kind == parse.EventListEnd || next.Kind == parse.EventDictEnd {
+			_, err = d.stream.Next()
+			return node, err
+		}
+		if next.Kind == parse.EventKey {
+			d.stream.Next()
+			node.Keys = append(node.Keys, parse.NewStringNode(next.Value, next.LineNo, next.ColNo))
+		}
+		item, err := d.readNode()
+		if err != nil {
+			return nil, err
+		}
+		node.Items = append(node.Items, item)
+	}
+}
@@ -0,0 +1,111 @@
+package nestedtext
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecoderToken(t *testing.T) {
+	input := `name: app
+hosts:
+    - a
+    -
+        [b, c]
+`
+	dec := NewDecoder(strings.NewReader(input))
+	want := []Token{
+		{TokenDictStart, "", 1, 1},
+		{TokenKey, "name", 1, 1},
+		{TokenString, "app", 1, 7},
+		{TokenKey, "hosts", 2, 1},
+		{TokenListStart, "", 3, 5},
+		{TokenString, "a", 3, 7},
+		{TokenListStart, "", 5, 9},
+		{TokenString, "b", 5, 10},
+		{TokenString, "c", 5, 13},
+		{TokenListEnd, "", 5, 9},
+		{TokenListEnd, "", 3, 5},
+		{TokenDictEnd, "", 1, 1},
+	}
+	var got []Token
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Token failed: %v", err)
+		}
+		got = append(got, tok)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens:\ngot  %v\nwant %v", got, want)
+	}
+}
+
+func TestDecoderTokenWithDecode(t *testing.T) {
+	type Record struct {
+		ID   int    `nt:"id"`
+		Name string `nt:"name"`
+	}
+	input := `
+-
+    id: 1
+    name: one
+-
+    id: 2
+    name: two
+-
+    {id: 3, name: three}
+`
+	dec := NewDecoder(strings.NewReader(input))
+	if tok, err := dec.Token(); err != nil || tok.Kind != TokenListStart {
+		t.Fatalf("expected list start, got %v, %v", tok, err)
+	}
+	var records []Record
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("Decode failed: %v", err)
+		}
+		records = append(records, rec)
+	}
+	want := []Record{{1, "one"}, {2, "two"}, {3, "three"}}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("got %v, want %v", records, want)
+	}
+	if tok, err := dec.Token(); err != nil || tok.Kind != TokenListEnd {
+		t.Errorf("expected list end, got %v, %v", tok, err)
+	}
+	var rec Record
+	if err := dec.Decode(&rec); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoderTokenErrors(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("a: b\na: c\n"))
+	var err error
+	for err == nil {
+		_, err = dec.Token()
+	}
+	var nte NestedTextError
+	if !errors.As(err, &nte) || nte.Line != 2 {
+		t.Errorf("expected duplicate key error on line 2, got %v", err)
+	}
+
+	dec = NewDecoder(strings.NewReader("[a, b]\n"), Minimal())
+	if _, err := dec.Token(); err == nil {
+		t.Error("expected error for inline list in minimal mode")
+	}
+
+	dec = NewDecoder(strings.NewReader("a: b\n"))
+	dec.Token()
+	var s string
+	if err := dec.Decode(&s); err == nil {
+		t.Error("expected error decoding at a key")
+	}
+}
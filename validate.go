@@ -0,0 +1,271 @@
+package nestedtext
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/danielledeleo/nestedtext/internal/parse"
+)
+
+// Struct fields may carry constraints on their decoded values in an "ntvalidate"
+// tag, as in `ntvalidate:"min=1,max=65535"`. The constraints are:
+//
+//   - min=n, max=n: bounds for numbers, or for the length of strings, slices,
+//     arrays and maps. They are an error on fields of other types.
+//   - nonempty: the value must not be zero, or must have a non-zero length.
+//   - oneof=a|b|c: the NestedText string must be one of the given ones.
+//   - pattern=regexp: the NestedText string must match the regular expression.
+//     Commas within brackets or braces do not separate constraints, so
+//     `pattern=^[a-z]{1,8}$` may be given literally.
+//
+// oneof and pattern apply to the text of the input rather than the decoded value,
+// so they work for any type decoded from a string. Constraints are checked for
+// fields present in the input; see the "required" tag option for absent ones.
+
+// constraint is a parsed ntvalidate constraint.
+type constraint struct {
+	text    string         // constraint as given in the tag, for messages
+	name    string         // min, max, nonempty, oneof or pattern
+	bound   string         // argument of min and max
+	choices []string       // argument of oneof
+	pattern *regexp.Regexp // argument of pattern
+}
+
+// parseConstraints parses the value of an "ntvalidate" tag on a field of type t.
+func parseConstraints(tag string, t reflect.Type) ([]constraint, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	var constraints []constraint
+	for _, text := range splitTagOptions(tag) {
+		name, arg, hasArg := strings.Cut(text, "=")
+		c := constraint{text: text, name: name}
+		switch {
+		case name == "nonempty" && !hasArg:
+		case (name == "min" || name == "max") && hasArg:
+			if _, err := strconv.ParseFloat(arg, 64); err != nil {
+				return nil, fmt.Errorf("invalid bound in %q", text)
+			}
+			if !hasBounds(t) {
+				return nil, fmt.Errorf("%q does not apply to values of type %s", text, t)
+			}
+			c.bound = arg
+		case name == "oneof" && hasArg:
+			c.choices = strings.Split(arg, "|")
+		case name == "pattern" && hasArg:
+			re, err := regexp.Compile(arg)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern in %q: %w", text, err)
+			}
+			c.pattern = re
+		default:
+			return nil, fmt.Errorf("invalid constraint %q", text)
+		}
+		constraints = append(constraints, c)
+	}
+	return constraints, nil
+}
+
+// checkConstraintTags reports the first malformed ntvalidate tag among the fields
+// of a struct, whether or not they are present in the input.
+func checkConstraintTags(info *structInfo) error {
+	for i := range info.fields {
+		if fi := &info.fields[i]; fi.constraintErr != nil {
+			return wrapError(ErrCodeUsage,
+				fmt.Sprintf("invalid ntvalidate tag for field %s: %v", fi.name, fi.constraintErr), fi.constraintErr)
+		}
+	}
+	return nil
+}
+
+// validateField checks the ntvalidate constraints of field fi against its decoded
+// value v, which was decoded from n.
+func validateField(fi *fieldInfo, n *parse.Node, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	var errs ErrorList
+	for _, c := range fi.constraints {
+		if ok, value := c.check(n, v); !ok {
+			errs = errs.add(&ValidationError{
+				Constraint: c.text,
+				Value:      value,
+				Type:       v.Type(),
+				Line:       n.LineNo,
+				Column:     n.ColNo,
+			})
+		}
+	}
+	return errs.err()
+}
+
+// check reports whether value v, decoded from n, satisfies c. If not, it also
+// returns a description of the offending value.
+func (c *constraint) check(n *parse.Node, v reflect.Value) (bool, string) {
+	switch c.name {
+	case "nonempty":
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+			return v.Len() > 0, "empty " + typeNameOf(n)
+		}
+		return !v.IsZero(), "zero value"
+	case "oneof", "pattern":
+		if n.Kind != parse.StringNode {
+			return false, typeNameOf(n)
+		}
+		if c.pattern != nil {
+			return c.pattern.MatchString(n.Value), fmt.Sprintf("string %q", n.Value)
+		}
+		for _, choice := range c.choices {
+			if n.Value == choice {
+				return true, ""
+			}
+		}
+		return false, fmt.Sprintf("string %q", n.Value)
+	}
+
+	// min and max
+	var cmp int
+	var value string
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map:
+		cmp = compareInt(int64(v.Len()), c.bound)
+		value = fmt.Sprintf("%s of length %d", typeNameOf(n), v.Len())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cmp = compareInt(v.Int(), c.bound)
+		value = fmt.Sprintf("value %d", v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cmp = compareUint(v.Uint(), c.bound)
+		value = fmt.Sprintf("value %d", v.Uint())
+	case reflect.Float32, reflect.Float64:
+		bound, _ := strconv.ParseFloat(c.bound, 64)
+		cmp = compareFloat(v.Float(), bound)
+		value = fmt.Sprintf("value %v", v.Float())
+	default: // only nil pointers, as parseConstraints rejects bounds on other kinds
+		return false, fmt.Sprintf("value of type %s", v.Type())
+	}
+	if c.name == "min" {
+		return cmp >= 0, value
+	}
+	return cmp <= 0, value
+}
+
+// hasBounds reports whether min and max apply to values of type t, or to the
+// values t points to.
+func hasBounds(t reflect.Type) bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// compareInt compares a with bound exactly if bound is an integer, and as floats
+// otherwise.
+func compareInt(a int64, bound string) int {
+	b, err := strconv.ParseInt(bound, 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(bound, 64)
+		return compareFloat(float64(a), f)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareUint compares a with bound exactly if bound is a non-negative integer,
+// and as floats otherwise.
+func compareUint(a uint64, bound string) int {
+	b, err := strconv.ParseUint(bound, 10, 64)
+	if err != nil {
+		f, _ := strconv.ParseFloat(bound, 64)
+		return compareFloat(float64(a), f)
+	}
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// validateValue calls the ValidateNT method of v, or of the value it points to,
+// if it implements Validator. v was decoded from n.
+func validateValue(n *parse.Node, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	var validator Validator
+	if v.CanAddr() {
+		validator, _ = v.Addr().Interface().(Validator)
+	} else if v.IsValid() && v.CanInterface() {
+		validator, _ = v.Interface().(Validator)
+	}
+	if validator == nil {
+		return nil
+	}
+	if err := validator.ValidateNT(); err != nil {
+		return &ValidationError{
+			Constraint: "ValidateNT",
+			Value:      typeNameOf(n),
+			Type:       v.Type(),
+			Line:       n.LineNo,
+			Column:     n.ColNo,
+			Err:        err,
+		}
+	}
+	return nil
+}
+
+// isValidationError reports whether err consists of validation errors only. These
+// do not stop decoding, so that all violations are reported.
+func isValidationError(err error) bool {
+	switch e := err.(type) {
+	case *ValidationError:
+		return true
+	case ErrorList:
+		for _, err := range e {
+			if !isValidationError(err) {
+				return false
+			}
+		}
+		return len(e) > 0
+	}
+	return false
+}
+
+// joinErrors combines two errors, either of which may be nil, into an ErrorList if
+// both are non-nil.
+func joinErrors(err1, err2 error) error {
+	switch {
+	case err1 == nil:
+		return err2
+	case err2 == nil:
+		return err1
+	}
+	return ErrorList{}.add(err1).add(err2)
+}
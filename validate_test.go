@@ -0,0 +1,164 @@
+package nestedtext
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type listener struct {
+	Host string `nt:"host" ntvalidate:"pattern=^[a-z0-9.]{1,63}$"`
+	Port int    `nt:"port" ntvalidate:"min=1,max=65535"`
+}
+
+// window validates that its bounds are ordered.
+type window struct {
+	From, To int
+}
+
+func (w *window) ValidateNT() error {
+	if w.From > w.To {
+		return fmt.Errorf("from %d is after to %d", w.From, w.To)
+	}
+	return nil
+}
+
+type serviceConfig struct {
+	Level     string     `nt:"level" ntvalidate:"oneof=debug|info|warn"`
+	Names     []string   `nt:"names" ntvalidate:"nonempty"`
+	Tags      []string   `nt:"tags" ntvalidate:"max=2"`
+	Ratio     float64    `nt:"ratio" ntvalidate:"min=0,max=1"`
+	Listeners []listener `nt:"listeners"`
+	Window    *window    `nt:"window"`
+}
+
+func TestUnmarshalValidation(t *testing.T) {
+	valid := `level: info
+names:
+  - api
+tags:
+  [a, b]
+ratio: 0.5
+listeners:
+  -
+    host: localhost
+    port: 8080
+window:
+  from: 1
+  to: 2
+`
+	var config serviceConfig
+	if err := Unmarshal([]byte(valid), &config); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+
+	invalid := `level: trace
+names:
+  []
+tags:
+  [a, b, c]
+ratio: 1.5
+listeners:
+  -
+    host: localhost
+    port: 8080
+  -
+    host: Bad_Host
+    port: 70000
+window:
+  from: 5
+  to: 2
+`
+	err := Unmarshal([]byte(invalid), &serviceConfig{})
+	var list ErrorList
+	if !errors.As(err, &list) {
+		t.Fatalf("got %v, want ErrorList", err)
+	}
+	want := []struct {
+		constraint, keyPath string
+		line, column        int
+	}{
+		{"oneof=debug|info|warn", "level", 1, 8},
+		{"nonempty", "names", 3, 3},
+		{"max=2", "tags", 5, 3},
+		{"min=0,max=1", "ratio", 6, 8},
+		{"pattern=^[a-z0-9.]{1,63}$", "listeners[1].host", 12, 11},
+		{"max=65535", "listeners[1].port", 13, 11},
+		{"ValidateNT", "window", 15, 3},
+	}
+	if len(list) != len(want) {
+		t.Fatalf("got %d errors, want %d:\n%v", len(list), len(want), err)
+	}
+	for i, w := range want {
+		var verr *ValidationError
+		if !errors.As(list[i], &verr) {
+			t.Errorf("error %d: got %v, want *ValidationError", i, list[i])
+			continue
+		}
+		if !strings.Contains(w.constraint, verr.Constraint) || verr.KeyPath != w.keyPath ||
+			verr.Line != w.line || verr.Column != w.column {
+			t.Errorf("error %d: got %s at %s [%d,%d], want %s at %s [%d,%d]", i,
+				verr.Constraint, verr.KeyPath, verr.Line, verr.Column, w.constraint, w.keyPath, w.line, w.column)
+		}
+	}
+	if msg := list[6].Error(); !strings.Contains(msg, "from 5 is after to 2") {
+		t.Errorf("ValidateNT error message %q lacks cause", msg)
+	}
+}
+
+func TestUnmarshalValidationSingleAndInvalidTag(t *testing.T) {
+	var l listener
+	err := Unmarshal([]byte("host: example.org\nport: 0\n"), &l)
+	var verr *ValidationError
+	if !errors.As(err, &verr) || verr.Constraint != "min=1" {
+		t.Errorf("got %v, want *ValidationError for min=1", err)
+	}
+	if !strings.Contains(err.Error(), "[2,7] value 0 violates min=1 at port") {
+		t.Errorf("unexpected message %q", err)
+	}
+
+	// Validation errors do not hide decoding errors
+	err = Unmarshal([]byte("host: example.org\nport: http\n"), &l)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) {
+		t.Errorf("got %v, want *UnmarshalTypeError", err)
+	}
+
+	var bad struct {
+		N int `ntvalidate:"min=x"`
+		M int
+	}
+	var nte NestedTextError
+	// Malformed tags are reported whether or not their fields are present
+	for _, input := range []string{"n: 1", "m: 1", ""} {
+		err = Unmarshal([]byte(input), &bad)
+		if !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+			t.Errorf("%q: got %v, want usage error", input, err)
+		}
+	}
+
+	// Bounds do not apply to all types
+	var unbounded struct {
+		On bool `nt:"on" ntvalidate:"min=1"`
+	}
+	if err := Unmarshal([]byte("on: true"), &unbounded); !errors.As(err, &nte) || nte.Code != ErrCodeUsage {
+		t.Errorf("got %v, want usage error for min on bool", err)
+	}
+}
+
+func TestUnmarshalValidationLargeIntegers(t *testing.T) {
+	var limits struct {
+		Signed   int64  `nt:"signed" ntvalidate:"max=9007199254740993"`
+		Unsigned uint64 `nt:"unsigned" ntvalidate:"min=18446744073709551615"`
+		Fraction int    `nt:"fraction" ntvalidate:"min=1.5"`
+	}
+	if err := Unmarshal([]byte("signed: 9007199254740993\nunsigned: 18446744073709551615\nfraction: 2\n"), &limits); err != nil {
+		t.Errorf("Unmarshal failed: %v", err)
+	}
+	input := "signed: 9007199254740994\nunsigned: 18446744073709551614\nfraction: 1\n"
+	var errs ErrorList
+	if err := Unmarshal([]byte(input), &limits); !errors.As(err, &errs) || len(errs) != 3 {
+		t.Errorf("got %v, want 3 validation errors", err)
+	}
+}